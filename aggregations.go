@@ -0,0 +1,345 @@
+package eso
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+// Aggregate runs the given aggregations (size 0) over the documents matching query.
+// A nil query aggregates over all documents of the index.
+func (s *DocType) Aggregate(ctx context.Context, query elastic.Query, aggs map[string]elastic.Aggregation) (elastic.Aggregations, error) {
+	q := s.cl.conn.Search(s.readIndex()).Size(0)
+	if !s.typeless {
+		q = q.Type(s.name)
+	}
+	if query != nil {
+		q = q.Query(query)
+	}
+	for name, agg := range aggs {
+		q = q.Aggregation(name, agg)
+	}
+
+	res, err := q.Do(ctx)
+	err = rateLimited(err)
+	if err != nil {
+		return nil, err
+	}
+	return res.Aggregations, nil
+}
+
+// CountBy counts the documents matching query grouped by the values of field (top size values).
+func (s *DocType) CountBy(ctx context.Context, field string, query elastic.Query, size int) (map[string]int64, error) {
+	aggs, err := s.Aggregate(ctx, query, map[string]elastic.Aggregation{
+		"count_by": elastic.NewTermsAggregation().Field(field).Size(size),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := DecodeAggregations(aggs)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int64{}
+	if node, ok := nodes["count_by"]; ok {
+		for _, b := range node.Buckets {
+			counts[b.Key] = b.DocCount
+		}
+	}
+	return counts, nil
+}
+
+// Cardinality returns the approximate number of distinct values of field in the documents matching
+// query (nil for all). Counts below 3000 are expected to be close to exact.
+func (s *DocType) Cardinality(ctx context.Context, field string, query interface{}) (int64, error) {
+	return s.CardinalityPrecision(ctx, field, query, 0)
+}
+
+// CardinalityPrecision is Cardinality with the precision_threshold below which counts are expected to be
+// close to exact (max 40000; more memory per shard). A threshold <= 0 uses the elasticsearch default.
+func (s *DocType) CardinalityPrecision(ctx context.Context, field string, query interface{}, precisionThreshold int64) (int64, error) {
+	agg := map[string]interface{}{"field": field}
+	if precisionThreshold > 0 {
+		agg["precision_threshold"] = precisionThreshold
+	}
+
+	res, err := s.search(ctx, []string{s.readIndex()}, query, []SearchOption{func(req *searchRequest) error {
+		req.body["size"] = 0
+		req.body["aggs"] = map[string]interface{}{"distinct": map[string]interface{}{"cardinality": agg}}
+		return nil
+	}})
+	if err != nil {
+		return 0, err
+	}
+
+	nodes, err := DecodeAggregations(res.Aggregations)
+	if err != nil {
+		return 0, err
+	}
+	node, ok := nodes["distinct"]
+	if !ok || node.Value == nil {
+		return 0, errors.New("cardinality aggregation missing in response")
+	}
+	return int64(*node.Value), nil
+}
+
+// allTermsPageSize is the number of buckets AllTerms fetches per request.
+const allTermsPageSize = 1000
+
+// AllTerms calls fn with every distinct value of field in the documents matching query (nil for all)
+// and its document count, paging through a composite aggregation ordered by value. Unlike CountBy
+// it is not limited to the top values. An error returned by fn stops the iteration and is returned.
+func (s *DocType) AllTerms(ctx context.Context, field string, query interface{}, fn func(key string, count int64) error) error {
+	var after map[string]interface{}
+	for {
+		composite := map[string]interface{}{
+			"size":    allTermsPageSize,
+			"sources": []interface{}{map[string]interface{}{"value": map[string]interface{}{"terms": map[string]interface{}{"field": field}}}},
+		}
+		if after != nil {
+			composite["after"] = after
+		}
+
+		res, err := s.search(ctx, []string{s.readIndex()}, query, []SearchOption{func(req *searchRequest) error {
+			req.body["size"] = 0
+			req.body["aggs"] = map[string]interface{}{"all": map[string]interface{}{"composite": composite}}
+			return nil
+		}})
+		if err != nil {
+			return err
+		}
+
+		raw, ok := res.Aggregations["all"]
+		if !ok || raw == nil {
+			return errors.New("composite aggregation missing in response")
+		}
+		var page struct {
+			AfterKey map[string]interface{} `json:"after_key"`
+			Buckets  []struct {
+				Key      map[string]interface{} `json:"key"`
+				DocCount int64                  `json:"doc_count"`
+			} `json:"buckets"`
+		}
+		if err := decodeSource(*raw, &page); err != nil {
+			return err
+		}
+
+		for _, b := range page.Buckets {
+			if err := fn(fmt.Sprint(b.Key["value"]), b.DocCount); err != nil {
+				return err
+			}
+		}
+		if len(page.Buckets) < allTermsPageSize || page.AfterKey == nil {
+			return nil
+		}
+		after = page.AfterKey
+	}
+}
+
+// AggNode is a decoded aggregation. Bucket aggregations carry their buckets, numeric metric
+// aggregations their value and top_hits aggregations their hits. Raw holds the undecoded
+// json for anything else (stats, percentiles, ...).
+type AggNode struct {
+	Value   *float64
+	Buckets []*Bucket
+	Hits    *elastic.SearchHits
+	Raw     json.RawMessage
+}
+
+// Bucket returns the bucket with the given key or nil.
+func (s *AggNode) Bucket(key string) *Bucket {
+	for _, b := range s.Buckets {
+		if b.Key == key {
+			return b
+		}
+	}
+	return nil
+}
+
+// Bucket is a single bucket of a bucket aggregation including its sub-aggregations.
+type Bucket struct {
+	Key      string
+	DocCount int64
+	Aggs     map[string]*AggNode
+}
+
+// Agg returns the sub-aggregation with the given name or nil.
+func (s *Bucket) Agg(name string) *AggNode {
+	return s.Aggs[name]
+}
+
+// DecodeAggregations recursively decodes aggregation results into a tree of buckets.
+func DecodeAggregations(aggs elastic.Aggregations) (map[string]*AggNode, error) {
+	nodes := make(map[string]*AggNode, len(aggs))
+	for name, raw := range aggs {
+		if raw == nil {
+			continue
+		}
+		node, err := decodeAggNode(*raw)
+		if err != nil {
+			return nil, err
+		}
+		nodes[name] = node
+	}
+	return nodes, nil
+}
+
+func decodeAggNode(raw json.RawMessage) (*AggNode, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+
+	node := &AggNode{Raw: raw}
+	if v, ok := fields["value"]; ok {
+		// non numeric values (scripted_metric objects, strings) are only kept in Raw
+		var value *float64
+		if json.Unmarshal(v, &value) == nil {
+			node.Value = value
+		}
+	}
+
+	if v, ok := fields["hits"]; ok {
+		if err := json.Unmarshal(v, &node.Hits); err != nil {
+			return nil, err
+		}
+		return node, nil
+	}
+
+	buckets, ok := fields["buckets"]
+	if !ok {
+		// single bucket aggregations (filter, nested, global, ...) carry doc_count and sub-aggregations directly
+		if _, ok := fields["doc_count"]; ok {
+			b, err := decodeBucket("", fields)
+			if err != nil {
+				return nil, err
+			}
+			node.Buckets = []*Bucket{b}
+		}
+		return node, nil
+	}
+
+	if strings.HasPrefix(strings.TrimSpace(string(buckets)), "{") {
+		// keyed buckets (range, filters with keyed: true)
+		var keyed map[string]map[string]json.RawMessage
+		if err := json.Unmarshal(buckets, &keyed); err != nil {
+			return nil, err
+		}
+		for key, bf := range keyed {
+			b, err := decodeBucket(key, bf)
+			if err != nil {
+				return nil, err
+			}
+			node.Buckets = append(node.Buckets, b)
+		}
+		return node, nil
+	}
+
+	var list []map[string]json.RawMessage
+	if err := json.Unmarshal(buckets, &list); err != nil {
+		return nil, err
+	}
+	for _, bf := range list {
+		b, err := decodeBucket("", bf)
+		if err != nil {
+			return nil, err
+		}
+		node.Buckets = append(node.Buckets, b)
+	}
+	return node, nil
+}
+
+func decodeBucket(key string, fields map[string]json.RawMessage) (*Bucket, error) {
+	b := &Bucket{Key: key, Aggs: map[string]*AggNode{}}
+
+	if v, ok := fields["key_as_string"]; ok {
+		if err := json.Unmarshal(v, &b.Key); err != nil {
+			return nil, err
+		}
+	} else if v, ok := fields["key"]; ok {
+		if err := json.Unmarshal(v, &b.Key); err != nil {
+			// numeric keys are kept in their json representation
+			b.Key = string(v)
+		}
+	}
+	if v, ok := fields["doc_count"]; ok {
+		if err := json.Unmarshal(v, &b.DocCount); err != nil {
+			return nil, err
+		}
+	}
+
+	for name, v := range fields {
+		if name == "key" || name == "key_as_string" || name == "doc_count" {
+			continue
+		}
+		if !strings.HasPrefix(strings.TrimSpace(string(v)), "{") {
+			continue
+		}
+		sub, err := decodeAggNode(v)
+		if err != nil {
+			return nil, err
+		}
+		b.Aggs[name] = sub
+	}
+	return b, nil
+}
+
+// DecodeTopHits decodes the documents of a top_hits aggregation nested in bucket aggregations.
+// path names the aggregations separated by ">" with the top_hits aggregation last (e.g. "by_user>latest").
+// The documents are keyed by bucket key, joined with ">" for multiple bucket levels.
+func DecodeTopHits[T any](aggs elastic.Aggregations, path string) (map[string][]T, error) {
+	nodes, err := DecodeAggregations(aggs)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := map[string][]T{}
+	if err := collectTopHits(nodes, strings.Split(path, ">"), "", ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func collectTopHits[T any](nodes map[string]*AggNode, names []string, key string, ret map[string][]T) error {
+	node, ok := nodes[names[0]]
+	if !ok {
+		return fmt.Errorf("aggregation %s not found", names[0])
+	}
+
+	if len(names) == 1 {
+		if node.Hits == nil {
+			return fmt.Errorf("aggregation %s is not a top_hits aggregation", names[0])
+		}
+
+		docs := make([]T, 0, len(node.Hits.Hits))
+		for _, hit := range node.Hits.Hits {
+			if hit.Source == nil {
+				continue
+			}
+			var doc T
+			if err := decodeSource(*hit.Source, &doc); err != nil {
+				return err
+			}
+			docs = append(docs, doc)
+		}
+		ret[key] = docs
+		return nil
+	}
+
+	for _, b := range node.Buckets {
+		k := b.Key
+		if key != "" {
+			k = key + ">" + b.Key
+		}
+		if err := collectTopHits(b.Aggs, names[1:], k, ret); err != nil {
+			return err
+		}
+	}
+	return nil
+}
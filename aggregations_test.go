@@ -0,0 +1,112 @@
+package eso
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+var decodeAggregationsTests = []struct {
+	aggs     string
+	path     []string
+	docCount int64
+	value    float64
+}{
+	{`{
+		"by_user": {
+			"buckets": [
+				{"key": "bob", "doc_count": 3, "per_day": {"buckets": [
+					{"key": 1483228800000, "key_as_string": "2017-01-01", "doc_count": 2, "avg_size": {"value": 12.5}},
+					{"key": 1483315200000, "key_as_string": "2017-01-02", "doc_count": 1, "avg_size": {"value": 4}}
+				]}}
+			]
+		}
+	}`, []string{"by_user", "bob", "per_day", "2017-01-01"}, 2, 12.5},
+	{`{
+		"only_active": {
+			"doc_count": 7,
+			"by_size": {"buckets": {"small": {"to": 10, "doc_count": 5, "avg_size": {"value": 3}}}}
+		}
+	}`, []string{"only_active", "", "by_size", "small"}, 5, 3},
+	{`{
+		"by_user": {
+			"buckets": [
+				{"key": "bob", "doc_count": 2, "avg_size": {"value": 1.5}, "profit": {"value": {"sum": 3, "count": 2}}}
+			]
+		}
+	}`, []string{"by_user", "bob"}, 2, 1.5},
+}
+
+func TestDecodeAggregations(t *testing.T) {
+	for _, tt := range decodeAggregationsTests {
+		var aggs elastic.Aggregations
+		if err := json.Unmarshal([]byte(tt.aggs), &aggs); err != nil {
+			t.Fatal(err)
+		}
+
+		nodes, err := DecodeAggregations(aggs)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		node := nodes[tt.path[0]]
+		var bucket *Bucket
+		for i := 1; i < len(tt.path); i += 2 {
+			if node == nil {
+				t.Fatalf("missing aggregation at %v", tt.path[:i])
+			}
+			if bucket = node.Bucket(tt.path[i]); bucket == nil {
+				t.Fatalf("missing bucket at %v", tt.path[:i+1])
+			}
+			if i+1 < len(tt.path) {
+				node = bucket.Agg(tt.path[i+1])
+			}
+		}
+
+		if bucket.DocCount != tt.docCount {
+			t.Error(bucket.DocCount, tt.docCount)
+		}
+		if avg := bucket.Agg("avg_size"); avg == nil || avg.Value == nil || *avg.Value != tt.value {
+			t.Error(avg, tt.value)
+		}
+		// scripted_metric values are objects, kept raw
+		if profit := bucket.Agg("profit"); profit != nil && (profit.Value != nil || len(profit.Raw) == 0) {
+			t.Error("expected raw scripted_metric value", profit)
+		}
+	}
+}
+
+func TestDecodeTopHits(t *testing.T) {
+	var aggs elastic.Aggregations
+	err := json.Unmarshal([]byte(`{
+		"by_user": {
+			"buckets": [
+				{"key": "bob", "doc_count": 3, "latest": {"hits": {"total": 3, "hits": [
+					{"_id": "3", "_source": {"test": "bling"}}
+				]}}},
+				{"key": "alice", "doc_count": 1, "latest": {"hits": {"total": 1, "hits": [
+					{"_id": "1", "_source": {"test": "bla"}}
+				]}}}
+			]
+		}
+	}`), &aggs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type doc struct {
+		Test string `json:"test"`
+	}
+	docs, err := DecodeTopHits[doc](aggs, "by_user>latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 2 || len(docs["bob"]) != 1 || docs["bob"][0].Test != "bling" || docs["alice"][0].Test != "bla" {
+		t.Error(docs)
+	}
+
+	if _, err := DecodeTopHits[doc](aggs, "by_user>missing"); err == nil {
+		t.Error("expected error for missing aggregation")
+	}
+}
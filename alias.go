@@ -0,0 +1,107 @@
+package eso
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+// GetAliases returns the sorted names of the aliases pointing at the index (or at the
+// indices behind it if the index name itself is an alias or pattern).
+func (s *Index) GetAliases(ctx context.Context) ([]string, error) {
+	res, err := s.cl.perform(ctx, "GET", "/"+s.name+"/_alias", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var indices map[string]struct {
+		Aliases map[string]json.RawMessage `json:"aliases"`
+	}
+	if err := json.Unmarshal(res.Body, &indices); err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	aliases := []string{}
+	for _, idx := range indices {
+		for name := range idx.Aliases {
+			if !seen[name] {
+				seen[name] = true
+				aliases = append(aliases, name)
+			}
+		}
+	}
+	sort.Strings(aliases)
+	return aliases, nil
+}
+
+// SetupRolloverAlias prepares rollover of the time series indices behind alias on the cluster of a registered
+// client: it puts the index template templateBody for templatePattern (named like the alias) and creates the
+// first index alias-000001 with alias as its write alias. It is idempotent and safe to call on every startup:
+// the template is updated, and once the alias exists, e.g. after rollovers, the indices are left untouched.
+func SetupRolloverAlias(ctx context.Context, clientName, alias, templatePattern, templateBody string) error {
+	first := alias + "-000001"
+	if !wildcardMatch(templatePattern, first) {
+		return fmt.Errorf("template pattern %s does not match the first index %s", templatePattern, first)
+	}
+
+	cl, err := getClient(clientName)
+	if err != nil {
+		return err
+	}
+
+	template := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(templateBody), &template); err != nil {
+		return fmt.Errorf("template body: %v", err)
+	}
+	template["index_patterns"] = []string{templatePattern}
+	res, err := cl.perform(ctx, "PUT", "/_template/"+url.PathEscape(alias), nil, template)
+	if err != nil {
+		return err
+	}
+	if err := checkAck(res.Body, "template"); err != nil {
+		return err
+	}
+
+	_, err = cl.perform(ctx, "GET", "/_alias/"+url.PathEscape(alias), nil, nil)
+	if err == nil {
+		return nil
+	}
+	if !elastic.IsNotFound(err) {
+		return err
+	}
+
+	body := map[string]interface{}{
+		"aliases": map[string]interface{}{alias: map[string]interface{}{"is_write_index": true}},
+	}
+	res, err = cl.perform(ctx, "PUT", "/"+url.PathEscape(first), nil, body)
+	if alreadyExists(err) {
+		// the first index was created without the alias (or concurrently): attach it
+		actions := map[string]interface{}{"actions": []interface{}{
+			map[string]interface{}{"add": map[string]interface{}{"index": first, "alias": alias, "is_write_index": true}},
+		}}
+		res, err = cl.perform(ctx, "POST", "/_aliases", nil, actions)
+	}
+	if err != nil {
+		return err
+	}
+	return checkAck(res.Body, "rollover alias")
+}
+
+// checkAck returns an error if the response body does not acknowledge the request.
+func checkAck(body json.RawMessage, what string) error {
+	var ack struct {
+		Acknowledged bool `json:"acknowledged"`
+	}
+	if err := json.Unmarshal(body, &ack); err != nil {
+		return err
+	}
+	if !ack.Acknowledged {
+		return fmt.Errorf("elasticsearch did not acknowledge %s", what)
+	}
+	return nil
+}
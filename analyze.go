@@ -0,0 +1,31 @@
+package eso
+
+import (
+	"context"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+// Analyze runs text through an analyzer of the index (or a built-in one like "standard")
+// and returns the tokens with their positions and offsets.
+func (s *Index) Analyze(ctx context.Context, analyzer, text string) ([]elastic.IndicesAnalyzeResponseToken, error) {
+	return s.analyze(ctx, s.cl.conn.IndexAnalyze().Index(s.name).Analyzer(analyzer).Text(text))
+}
+
+// AnalyzeChain runs text through an ad-hoc analysis chain of tokenizer and token filters
+// (e.g. "standard", "lowercase", "asciifolding") without defining an analyzer in the index.
+func (s *Index) AnalyzeChain(ctx context.Context, tokenizer string, filters []string, text string) ([]elastic.IndicesAnalyzeResponseToken, error) {
+	q := s.cl.conn.IndexAnalyze().Index(s.name).Tokenizer(tokenizer).Text(text)
+	if len(filters) != 0 {
+		q = q.Filter(filters...)
+	}
+	return s.analyze(ctx, q)
+}
+
+func (s *Index) analyze(ctx context.Context, q *elastic.IndicesAnalyzeService) ([]elastic.IndicesAnalyzeResponseToken, error) {
+	res, err := q.Do(ctx)
+	if err != nil {
+		return nil, rateLimited(err)
+	}
+	return res.Tokens, nil
+}
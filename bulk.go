@@ -0,0 +1,585 @@
+package eso
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+var (
+	// DefaultBulkSize is the number of documents sent per bulk request if no size is given.
+	DefaultBulkSize = 500
+	// DefaultBulkRetries is how often IndexMany and BulkIndexer resend items rejected with 429.
+	DefaultBulkRetries = 3
+)
+
+// BulkItemError describes a single failed item of a bulk request.
+type BulkItemError struct {
+	Index  string
+	ID     string
+	Status int
+	// Type and Reason are the error type (e.g. mapper_parsing_exception) and message.
+	Type   string
+	Reason string
+}
+
+func (s BulkItemError) Error() string {
+	return fmt.Sprintf("%s/%s: status %d %s: %s", s.Index, s.ID, s.Status, s.Type, s.Reason)
+}
+
+// Is reports items rejected with 429 as ErrRateLimited and items with fields not allowed
+// by a strict mapping as ErrStrictMapping.
+func (s BulkItemError) Is(target error) bool {
+	switch target {
+	case ErrRateLimited:
+		return s.Status == http.StatusTooManyRequests
+	case ErrStrictMapping:
+		return s.Type == "strict_dynamic_mapping_exception"
+	}
+	return false
+}
+
+// BulkError is returned when some items of a bulk request failed.
+type BulkError struct {
+	Items []BulkItemError
+}
+
+func (s *BulkError) Error() string {
+	reasons := make([]string, 0, len(s.Items))
+	for _, item := range s.Items {
+		reasons = append(reasons, item.Error())
+	}
+	return fmt.Sprintf("%d bulk items failed: %s", len(s.Items), strings.Join(reasons, "; "))
+}
+
+// Is matches ErrRateLimited if any item was rejected with 429 (retries exhausted) and
+// ErrStrictMapping if any item has fields not allowed by a strict mapping.
+func (s *BulkError) Is(target error) bool {
+	for _, item := range s.Items {
+		if item.Is(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// BulkItemErrors returns all items of the bulk response which were not successful.
+func BulkItemErrors(res *elastic.BulkResponse) []BulkItemError {
+	var items []*elastic.BulkResponseItem
+	for _, item := range res.Items {
+		for _, r := range item {
+			items = append(items, r)
+		}
+	}
+	return bulkItemErrors(items)
+}
+
+func bulkItemErrors(items []*elastic.BulkResponseItem) []BulkItemError {
+	var failed []BulkItemError
+	for _, r := range items {
+		if r == nil || (r.Error == nil && r.Status < 300) {
+			continue
+		}
+
+		itemErr := BulkItemError{Index: r.Index, ID: r.Id, Status: r.Status}
+		if r.Error != nil {
+			itemErr.Type = r.Error.Type
+			itemErr.Reason = r.Error.Reason
+		}
+		failed = append(failed, itemErr)
+	}
+	return failed
+}
+
+// doBulk sends reqs in one bulk request and resends the items rejected with 429 (too many requests)
+// up to retries times with exponential backoff. Other failures are not retried. The returned
+// response items are in the order of reqs. On errors unsent holds the positions in reqs which
+// elasticsearch has not accepted yet; the others must not be sent again.
+func (s *DocType) doBulk(ctx context.Context, reqs []elastic.BulkableRequest, opts []WriteOption, retries int) (items []*elastic.BulkResponseItem, unsent []int, err error) {
+	var (
+		pending = make([]int, len(reqs))
+		backoff = 100 * time.Millisecond
+	)
+	items = make([]*elastic.BulkResponseItem, len(reqs))
+	for i := range reqs {
+		pending[i] = i
+	}
+
+	for attempt := 0; ; attempt++ {
+		bulk, err := s.bulkService(opts)
+		if err != nil {
+			return items, pending, err
+		}
+		for _, i := range pending {
+			bulk.Add(reqs[i])
+		}
+
+		res, err := bulk.Do(ctx)
+		err = rateLimited(err)
+		if err != nil {
+			return items, pending, err
+		}
+
+		var retry []int
+		for j, item := range res.Items {
+			for _, r := range item {
+				items[pending[j]] = r
+				if r.Status == http.StatusTooManyRequests && attempt < retries {
+					retry = append(retry, pending[j])
+				}
+			}
+		}
+		if len(retry) == 0 {
+			return items, nil, nil
+		}
+		pending = retry
+
+		select {
+		case <-ctx.Done():
+			return items, pending, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// bulkIndexRequest creates the bulk item indexing doc. Of the write options only op_type
+// (CreateOnly) applies to single items; the others are applied by bulkService.
+func (s *DocType) bulkIndexRequest(doc interface{}, id string, opts []WriteOption) *elastic.BulkIndexRequest {
+	params := url.Values{}
+	for _, opt := range opts {
+		opt(params)
+	}
+
+	req := elastic.NewBulkIndexRequest().Index(s.writeIndex()).Type(s.mappingType()).Doc(doc)
+	if id != "" {
+		req = req.Id(id)
+	}
+	if v := params.Get("op_type"); v != "" {
+		req = req.OpType(v)
+	}
+	return req
+}
+
+// bulkService creates a bulk service and applies the write options valid for whole bulk
+// requests (wait_for_active_shards, refresh). IfSeqNo is bound to a single document and
+// therefore an error.
+func (s *DocType) bulkService(opts []WriteOption) (*elastic.BulkService, error) {
+	params := url.Values{}
+	for _, opt := range opts {
+		opt(params)
+	}
+	if params.Get("if_seq_no") != "" || params.Get("if_primary_term") != "" {
+		return nil, errors.New("IfSeqNo is not supported for bulk requests")
+	}
+
+	bulk := s.cl.conn.Bulk()
+	if v := params.Get("wait_for_active_shards"); v != "" {
+		bulk = bulk.WaitForActiveShards(v)
+	}
+	if v := params.Get("refresh"); v != "" {
+		bulk = bulk.Refresh(v)
+	}
+	return bulk, nil
+}
+
+// NewBulkIndexer creates a BulkIndexer sending a bulk request every size documents.
+// A size <= 0 uses DefaultBulkSize.
+func NewBulkIndexer(docType *DocType, size int, opts ...WriteOption) *BulkIndexer {
+	if size <= 0 {
+		size = DefaultBulkSize
+	}
+	return &BulkIndexer{
+		docType: docType,
+		size:    size,
+		retries: DefaultBulkRetries,
+		opts:    opts,
+		stats:   BulkStats{BatchSize: size},
+	}
+}
+
+// BulkIndexer buffers documents and indexes them in bulk requests.
+// Call Flush when done to send the remaining documents.
+type BulkIndexer struct {
+	docType *DocType
+	size    int
+	retries int
+	opts    []WriteOption
+	reqs    []elastic.BulkableRequest
+
+	adaptive *adaptiveSize
+
+	m     sync.Mutex
+	stats BulkStats
+}
+
+// BulkStats holds the counters of a BulkIndexer.
+type BulkStats struct {
+	// Buffered is the number of documents waiting for the next flush.
+	Buffered int
+	// Flushed is the total number of documents sent to elasticsearch.
+	Flushed int64
+	// Failed is the total number of documents elasticsearch rejected.
+	Failed int64
+	// LastFlush is the duration of the last bulk request.
+	LastFlush time.Duration
+	// BatchSize is the current number of documents per bulk request.
+	BatchSize int
+}
+
+// Stats returns the current counters. It is safe to call while documents are added.
+func (s *BulkIndexer) Stats() BulkStats {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.stats
+}
+
+// Adaptive makes the indexer tune its batch size between min and max: the size is doubled
+// after successful flushes faster than half of target and halved after flushes slower than target
+// or with items rejected with 429 (too many requests). Other request errors leave the size unchanged.
+// The size set on creation is the start value, clamped to the bounds. A max below min is raised to min.
+func (s *BulkIndexer) Adaptive(min, max int, target time.Duration) *BulkIndexer {
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	s.adaptive = &adaptiveSize{min: min, max: max, target: target}
+	s.setSize(s.adaptive.clamp(s.size))
+	return s
+}
+
+type adaptiveSize struct {
+	min, max int
+	target   time.Duration
+}
+
+// next returns the batch size following a flush of the given size which took took. failed is
+// set for request errors, rateLimited if the request or some of its items were rejected with 429.
+func (s *adaptiveSize) next(size int, took time.Duration, failed, rateLimited bool) int {
+	switch {
+	case rateLimited:
+		size /= 2
+	case failed:
+	case took > s.target:
+		size /= 2
+	case took < s.target/2:
+		size *= 2
+	}
+	return s.clamp(size)
+}
+
+func (s *adaptiveSize) clamp(size int) int {
+	if size < s.min {
+		return s.min
+	}
+	if size > s.max {
+		return s.max
+	}
+	return size
+}
+
+// adapt adjusts the batch size after a flush if the indexer is adaptive.
+func (s *BulkIndexer) adapt(took time.Duration, failed, rateLimited bool) {
+	if s.adaptive != nil {
+		s.setSize(s.adaptive.next(s.size, took, failed, rateLimited))
+	}
+}
+
+func (s *BulkIndexer) setSize(size int) {
+	s.size = size
+	s.m.Lock()
+	s.stats.BatchSize = size
+	s.m.Unlock()
+}
+
+// Retries sets how often items rejected with 429 (too many requests) are resent.
+func (s *BulkIndexer) Retries(retries int) *BulkIndexer {
+	s.retries = retries
+	return s
+}
+
+// BulkItem is a document along with its own metadata for BulkIndexer.AddItem.
+type BulkItem struct {
+	Doc interface{}
+	// ID may be empty to let elasticsearch generate one.
+	ID      string
+	Routing string
+	// Version is only sent if not 0. VersionType defaults to internal.
+	Version     int64
+	VersionType string
+	// OpType is index (default) or create.
+	OpType string
+}
+
+// Add adds a document to the buffer. An empty id lets elasticsearch generate one.
+// The buffer is flushed once it holds size documents.
+func (s *BulkIndexer) Add(doc interface{}, id string) error {
+	return s.AddItem(BulkItem{Doc: doc, ID: id})
+}
+
+// AddTo adds a document for index instead of the index of the doc type, so a single
+// bulk request can write to several indices (e.g. one per tenant).
+func (s *BulkIndexer) AddTo(index string, doc interface{}, id string) error {
+	return s.add(s.docType.bulkIndexRequest(doc, id, s.opts).Index(index))
+}
+
+// AddItem adds a document with per document routing, versioning and op type to the buffer.
+// The buffer is flushed once it holds size documents.
+func (s *BulkIndexer) AddItem(item BulkItem) error {
+	req := s.docType.bulkIndexRequest(item.Doc, item.ID, s.opts)
+	if item.Routing != "" {
+		req = req.Routing(item.Routing)
+	}
+	if item.Version != 0 {
+		req = req.Version(item.Version)
+	}
+	if item.VersionType != "" {
+		req = req.VersionType(item.VersionType)
+	}
+	if item.OpType != "" {
+		req = req.OpType(item.OpType)
+	}
+	return s.add(req)
+}
+
+func (s *BulkIndexer) add(req elastic.BulkableRequest) error {
+	s.reqs = append(s.reqs, req)
+	buffered := len(s.reqs)
+
+	s.m.Lock()
+	s.stats.Buffered = buffered
+	s.m.Unlock()
+
+	if buffered < s.size {
+		return nil
+	}
+	return s.Flush(context.TODO())
+}
+
+// Flush sends all buffered documents. Items rejected with 429 are retried, other failed
+// documents are reported as *BulkError. On request errors the documents elasticsearch has
+// not accepted yet stay buffered for the next Flush.
+func (s *BulkIndexer) Flush(ctx context.Context) error {
+	count := len(s.reqs)
+	if count == 0 {
+		return nil
+	}
+
+	start := time.Now()
+	items, unsent, err := s.docType.doBulk(ctx, s.reqs, s.opts, s.retries)
+	took := time.Since(start)
+
+	var reqs []elastic.BulkableRequest
+	if err != nil {
+		// items of unsent positions are left over from earlier attempts
+		isUnsent := make(map[int]bool, len(unsent))
+		for _, i := range unsent {
+			reqs = append(reqs, s.reqs[i])
+			isUnsent[i] = true
+		}
+		for i := range items {
+			if isUnsent[i] {
+				items[i] = nil
+			}
+		}
+	}
+	failed := bulkItemErrors(items)
+	s.reqs = reqs
+	s.adapt(took, err != nil, errors.Is(err, ErrRateLimited) || (&BulkError{Items: failed}).Is(ErrRateLimited))
+
+	s.m.Lock()
+	s.stats.Buffered = len(reqs)
+	s.stats.Flushed += int64(count - len(reqs))
+	s.stats.Failed += int64(len(failed))
+	s.stats.LastFlush = took
+	s.m.Unlock()
+
+	if len(failed) != 0 {
+		return errors.Join(err, &BulkError{Items: failed})
+	}
+	return err
+}
+
+// IndexMany indexes all docs in bulk requests of DefaultBulkSize documents (see ChunkSize) and returns
+// the ids in the order of docs. idFn may be nil to let elasticsearch generate the ids.
+// Documents rejected with 429 are retried DefaultBulkRetries times, other failed
+// documents are collected over all requests and returned as *BulkError.
+func (s *DocType) IndexMany(ctx context.Context, docs []interface{}, idFn func(doc interface{}) string, opts ...WriteOption) ([]string, error) {
+	params := url.Values{}
+	for _, opt := range opts {
+		opt(params)
+	}
+	size, _ := strconv.Atoi(params.Get(chunkSizeParam))
+	if size <= 0 {
+		size = DefaultBulkSize
+	}
+
+	var (
+		ids    = make([]string, 0, len(docs))
+		failed []BulkItemError
+	)
+	for start := 0; start < len(docs); start += size {
+		end := start + size
+		if end > len(docs) {
+			end = len(docs)
+		}
+
+		reqs := make([]elastic.BulkableRequest, 0, end-start)
+		for _, doc := range docs[start:end] {
+			var id string
+			if idFn != nil {
+				id = idFn(doc)
+			}
+			reqs = append(reqs, s.bulkIndexRequest(doc, id, opts))
+		}
+
+		items, _, err := s.doBulk(ctx, reqs, opts, DefaultBulkRetries)
+		if err != nil {
+			return ids, err
+		}
+		for _, r := range items {
+			ids = append(ids, r.Id)
+		}
+		failed = append(failed, bulkItemErrors(items)...)
+	}
+
+	if len(failed) != 0 {
+		return ids, &BulkError{Items: failed}
+	}
+	return ids, nil
+}
+
+// BulkUpdate merges the partial documents into the documents with the given ids (id -> partial)
+// in bulk requests of DefaultBulkSize documents. Failed items, including ids not found
+// (status 404, document_missing_exception), are returned as *BulkError.
+func (s *DocType) BulkUpdate(ctx context.Context, updates map[string]interface{}) error {
+	var (
+		failed []BulkItemError
+		reqs   = make([]elastic.BulkableRequest, 0, DefaultBulkSize)
+	)
+	flush := func() error {
+		items, _, err := s.doBulk(ctx, reqs, nil, DefaultBulkRetries)
+		if err != nil {
+			return err
+		}
+		failed = append(failed, bulkItemErrors(items)...)
+		reqs = reqs[:0]
+		return nil
+	}
+
+	for id, partial := range updates {
+		if str, ok := partial.(string); ok {
+			partial = json.RawMessage(str)
+		}
+		reqs = append(reqs, elastic.NewBulkUpdateRequest().Index(s.writeIndex()).Type(s.mappingType()).Id(id).Doc(partial))
+		if len(reqs) < DefaultBulkSize {
+			continue
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+	if len(reqs) != 0 {
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+
+	if len(failed) != 0 {
+		return &BulkError{Items: failed}
+	}
+	return nil
+}
+
+// IndexManyByField indexes docs like IndexMany using the value of idField of every document as its id.
+// All documents are checked before indexing; a missing or empty id field is an error.
+func (s *DocType) IndexManyByField(ctx context.Context, docs []map[string]interface{}, idField string, opts ...WriteOption) ([]string, error) {
+	items := make([]interface{}, 0, len(docs))
+	for i, doc := range docs {
+		v, ok := doc[idField]
+		if !ok || v == nil || v == "" {
+			return nil, fmt.Errorf("document %d has no id field %s", i, idField)
+		}
+		items = append(items, doc)
+	}
+
+	return s.IndexMany(ctx, items, func(doc interface{}) string {
+		v := doc.(map[string]interface{})[idField]
+		if id, ok := v.(string); ok {
+			return id
+		}
+		return fmt.Sprint(v)
+	}, opts...)
+}
+
+// BulkFromNDJSON reads newline delimited json documents from r and indexes them in bulk requests of
+// DefaultBulkSize documents, holding only one batch in memory. idFn may be nil to let elasticsearch
+// generate the ids. Malformed lines are skipped and, together with the failed documents, counted in
+// failed and returned as *BulkError (type malformed_line, reason naming the line number).
+func (s *DocType) BulkFromNDJSON(ctx context.Context, r io.Reader, idFn func(line json.RawMessage) string, opts ...WriteOption) (indexed int64, failed int64, err error) {
+	var (
+		errs []BulkItemError
+		reqs = make([]elastic.BulkableRequest, 0, DefaultBulkSize)
+		rd   = bufio.NewReader(r)
+	)
+	flush := func() error {
+		items, _, err := s.doBulk(ctx, reqs, opts, DefaultBulkRetries)
+		if err != nil {
+			return err
+		}
+		itemErrs := bulkItemErrors(items)
+		errs = append(errs, itemErrs...)
+		indexed += int64(len(items) - len(itemErrs))
+		failed += int64(len(itemErrs))
+		reqs = reqs[:0]
+		return nil
+	}
+
+	for lineNo := 1; ; lineNo++ {
+		line, readErr := rd.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return indexed, failed, readErr
+		}
+
+		if line = bytes.TrimSpace(line); len(line) != 0 {
+			if json.Valid(line) {
+				var id string
+				if idFn != nil {
+					id = idFn(line)
+				}
+				reqs = append(reqs, s.bulkIndexRequest(json.RawMessage(line), id, opts))
+			} else {
+				errs = append(errs, BulkItemError{Index: s.writeIndex(), Type: "malformed_line", Reason: fmt.Sprintf("line %d is not valid json", lineNo)})
+				failed++
+			}
+		}
+
+		if len(reqs) == DefaultBulkSize || (readErr == io.EOF && len(reqs) != 0) {
+			if err := flush(); err != nil {
+				return indexed, failed, err
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	if len(errs) != 0 {
+		return indexed, failed, &BulkError{Items: errs}
+	}
+	return indexed, failed, nil
+}
@@ -0,0 +1,193 @@
+package eso
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var bulkRetryTests = []struct {
+	responses []string
+	size      int
+	ids       []string
+	actions   []int
+	failed    int
+}{
+	{[]string{
+		`{"errors": true, "items": [
+			{"index": {"_index": "unit_test", "_id": "1", "status": 201}},
+			{"index": {"_index": "unit_test", "_id": "2", "status": 429, "error": {"type": "es_rejected_execution_exception", "reason": "queue full"}}}
+		]}`,
+		`{"errors": false, "items": [{"index": {"_index": "unit_test", "_id": "2", "status": 201}}]}`,
+	}, 0, []string{"1", "2"}, []int{2, 1}, 0},
+	{[]string{
+		`{"errors": true, "items": [
+			{"index": {"_index": "unit_test", "_id": "1", "status": 400, "error": {"type": "mapper_parsing_exception", "reason": "failed to parse"}}},
+			{"index": {"_index": "unit_test", "_id": "2", "status": 201}}
+		]}`,
+	}, 0, []string{"1", "2"}, []int{2}, 1},
+	{[]string{
+		`{"errors": false, "items": [{"index": {"_index": "unit_test", "_id": "1", "status": 201}}]}`,
+		`{"errors": false, "items": [{"index": {"_index": "unit_test", "_id": "2", "status": 201}}]}`,
+	}, 1, []string{"1", "2"}, []int{1, 1}, 0},
+}
+
+func TestIndexManyRetry(t *testing.T) {
+	for i, tt := range bulkRetryTests {
+		var actions []int
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			// every index action consists of an action and a source line
+			actions = append(actions, bytes.Count(body, []byte("\n"))/2)
+			w.Write([]byte(tt.responses[len(actions)-1]))
+		}))
+
+		name := fmt.Sprintf("bulk_test_%d", i)
+		RegisterClient(name, srv.URL)
+		doc := NewDocType(NewIndex("unit_test", name), "test")
+
+		ids, err := doc.IndexMany(context.Background(), []interface{}{`{"test": "bla"}`, `{"test": "blubb"}`},
+			func(doc interface{}) string {
+				if doc.(string) == `{"test": "bla"}` {
+					return "1"
+				}
+				return "2"
+			}, ChunkSize(tt.size))
+		srv.Close()
+
+		if bulkErr, ok := err.(*BulkError); tt.failed != 0 && (!ok || len(bulkErr.Items) != tt.failed) {
+			t.Error(err, tt.failed)
+		} else if tt.failed == 0 && err != nil {
+			t.Error(err)
+		}
+		if len(ids) != len(tt.ids) || ids[0] != tt.ids[0] || ids[1] != tt.ids[1] {
+			t.Error(ids, tt.ids)
+		}
+		if !reflect.DeepEqual(actions, tt.actions) {
+			t.Error(actions, tt.actions)
+		}
+	}
+}
+
+func TestFlushKeepsUnsent(t *testing.T) {
+	responses := []string{
+		`{"errors": true, "items": [
+			{"index": {"_index": "unit_test", "_id": "1", "status": 201}},
+			{"index": {"_index": "unit_test", "_id": "2", "status": 429, "error": {"type": "es_rejected_execution_exception", "reason": "queue full"}}}
+		]}`,
+		``,
+		`{"errors": false, "items": [{"index": {"_index": "unit_test", "_id": "2", "status": 201}}]}`,
+	}
+	var actions []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		actions = append(actions, bytes.Count(body, []byte("\n"))/2)
+		if responses[len(actions)-1] == "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error": {"type": "exception", "reason": "boom"}, "status": 500}`))
+			return
+		}
+		w.Write([]byte(responses[len(actions)-1]))
+	}))
+	defer srv.Close()
+
+	RegisterClient("bulk_test_unsent", srv.URL)
+	bi := NewBulkIndexer(NewDocType(NewIndex("unit_test", "bulk_test_unsent"), "test"), 10)
+	bi.Add(`{"test": "bla"}`, "1")
+	bi.Add(`{"test": "blubb"}`, "2")
+
+	if err := bi.Flush(context.Background()); err == nil {
+		t.Error("expected request error")
+	}
+	if stats := bi.Stats(); stats.Buffered != 1 || stats.Flushed != 1 {
+		t.Error("expected only the rejected document to stay buffered", stats)
+	}
+	if err := bi.Flush(context.Background()); err != nil {
+		t.Error(err)
+	}
+	if len(actions) != 3 || actions[2] != 1 {
+		t.Error("expected the accepted document not to be resent", actions)
+	}
+}
+
+func TestBulkWriteOptions(t *testing.T) {
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.Write([]byte(`{"errors": false, "items": [{"create": {"_index": "unit_test", "_id": "1", "status": 201}}]}`))
+	}))
+	defer srv.Close()
+
+	RegisterClient("bulk_test_options", srv.URL)
+	doc := NewDocType(NewIndex("unit_test", "bulk_test_options"), "test")
+	idFn := func(interface{}) string { return "1" }
+
+	if _, err := doc.IndexMany(context.Background(), []interface{}{`{"test": "bla"}`}, idFn, CreateOnly()); err != nil {
+		t.Error(err)
+	}
+	if len(bodies) != 1 || !strings.HasPrefix(bodies[0], `{"create":`) {
+		t.Error("expected a create action", bodies)
+	}
+
+	if _, err := doc.IndexMany(context.Background(), []interface{}{`{"test": "bla"}`}, idFn, IfSeqNo(1, 1)); err == nil {
+		t.Error("expected error for IfSeqNo")
+	}
+	if len(bodies) != 1 {
+		t.Error("expected no request with IfSeqNo", bodies)
+	}
+}
+
+var adaptiveSizeTests = []struct {
+	size        int
+	took        time.Duration
+	failed      bool
+	rateLimited bool
+	expected    int
+}{
+	{100, 10 * time.Millisecond, false, false, 200},
+	{100, 700 * time.Millisecond, false, false, 100},
+	{100, 2 * time.Second, false, false, 50},
+	{100, 10 * time.Millisecond, false, true, 50},
+	{100, 10 * time.Millisecond, true, false, 100},
+	{100, 2 * time.Second, true, false, 100},
+	{100, 10 * time.Millisecond, true, true, 50},
+	{800, 10 * time.Millisecond, false, false, 1000},
+	{15, 2 * time.Second, false, false, 10},
+}
+
+func TestAdaptiveSize(t *testing.T) {
+	a := &adaptiveSize{min: 10, max: 1000, target: time.Second}
+	for _, tt := range adaptiveSizeTests {
+		if actual := a.next(tt.size, tt.took, tt.failed, tt.rateLimited); actual != tt.expected {
+			t.Error(tt.size, tt.took, tt.failed, tt.rateLimited, actual, tt.expected)
+		}
+	}
+}
+
+var adaptiveBoundsTests = []struct {
+	size, min, max int
+	expected       int
+}{
+	{500, 10, 1000, 500},
+	{5000, 10, 1000, 1000},
+	{500, 1000, 2000, 1000},
+	{500, 10, 0, 10},
+	{500, 0, 0, 1},
+}
+
+func TestAdaptiveBounds(t *testing.T) {
+	for _, tt := range adaptiveBoundsTests {
+		bi := (&BulkIndexer{size: tt.size}).Adaptive(tt.min, tt.max, time.Second)
+		if bi.size != tt.expected || bi.Stats().BatchSize != tt.expected {
+			t.Error(tt.size, tt.min, tt.max, bi.size, tt.expected)
+		}
+	}
+}
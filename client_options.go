@@ -0,0 +1,89 @@
+package eso
+
+// ClientOption configures a client registered with RegisterClient.
+type ClientOption func(cfg *clientConfig)
+
+type clientConfig struct {
+	url     string
+	eager   bool
+	capture CaptureFunc
+	quiet   bool
+
+	inspect        InspectFunc
+	inspectHeaders []string
+
+	retries     int
+	isRetryable func(statusCode int, err error) bool
+
+	compatibleWith int
+
+	defaultSettings map[string]string
+}
+
+// Eager connects to the cluster and checks its health during RegisterClient so
+// configuration errors surface at startup instead of on the first request.
+func Eager() ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.eager = true
+	}
+}
+
+// DebugCapture calls fn with the method, url and body of every request sent by the client.
+// Use WithCapture to capture the requests of a single call only.
+func DebugCapture(fn CaptureFunc) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.capture = fn
+	}
+}
+
+// SilentErrorLog disables the error log the elastic client writes to stderr.
+// Errors are still returned to the caller.
+func SilentErrorLog() ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.quiet = true
+	}
+}
+
+// InspectResponses calls fn with the status code and headers of every response the client
+// receives, e.g. to watch Retry-After or X-Elastic-Product between a proxy and the cluster.
+// Only the given headers are passed; without headers fn receives all of them.
+func InspectResponses(fn InspectFunc, headers ...string) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.inspect = fn
+		cfg.inspectHeaders = headers
+	}
+}
+
+// RetryOn resends failed requests up to retries times with exponential backoff starting at 100ms
+// if isRetryable classifies the failure as retryable. err is set for connection errors, statusCode
+// otherwise. A nil isRetryable uses DefaultIsRetryable; pass a custom one to e.g. also retry the
+// 502s of a load balancer. Request bodies are kept in memory to resend them.
+// With the default isRetryable connection errors are only retried for idempotent requests (GET, HEAD,
+// PUT and POST on a document id): a bulk or an index request without id may have been executed before
+// the connection broke and would be written twice. A custom isRetryable is asked for all requests.
+func RetryOn(retries int, isRetryable func(statusCode int, err error) bool) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.retries = retries
+		cfg.isRetryable = isRetryable
+	}
+}
+
+// CompatibleWith sends the compatibility headers of the given major version (e.g. 8) with every request,
+// asking the cluster to accept and answer requests in the format of that version. It is required by
+// elasticsearch 8 clusters that reject requests of older clients otherwise.
+func CompatibleWith(major int) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.compatibleWith = major
+	}
+}
+
+// DefaultSettings are merged into the settings of every index created on the client (see Index.AddSetting
+// for the format). Settings added to the index itself take precedence over defaults with the same key.
+func DefaultSettings(settings map[string]string) ClientOption {
+	return func(cfg *clientConfig) {
+		cfg.defaultSettings = make(map[string]string, len(settings))
+		for key, value := range settings {
+			cfg.defaultSettings[key] = value
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package eso
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+)
+
+// ClusterVersion returns the elasticsearch version (e.g. "5.6.3") of the cluster behind a registered client.
+func ClusterVersion(ctx context.Context, clientName string) (string, error) {
+	cl, err := getClient(clientName)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := cl.perform(ctx, "GET", "/", nil, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var info struct {
+		Version struct {
+			Number string `json:"number"`
+		} `json:"version"`
+	}
+	if err := json.Unmarshal(res.Body, &info); err != nil {
+		return "", err
+	}
+	return info.Version.Number, nil
+}
+
+// ClusterSettings holds the transient and persistent cluster settings.
+type ClusterSettings struct {
+	Transient  map[string]interface{} `json:"transient"`
+	Persistent map[string]interface{} `json:"persistent"`
+}
+
+// ClusterGetSettings returns the transient and persistent cluster settings (flat keys).
+func ClusterGetSettings(ctx context.Context, clientName string) (*ClusterSettings, error) {
+	cl, err := getClient(clientName)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := cl.perform(ctx, "GET", "/_cluster/settings", url.Values{"flat_settings": []string{"true"}}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := new(ClusterSettings)
+	if err := json.Unmarshal(res.Body, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// ClusterPutSettings updates cluster settings. Either map may be nil; a nil value resets a setting.
+func ClusterPutSettings(ctx context.Context, clientName string, transient, persistent map[string]interface{}) error {
+	cl, err := getClient(clientName)
+	if err != nil {
+		return err
+	}
+
+	body := map[string]interface{}{}
+	if transient != nil {
+		body["transient"] = transient
+	}
+	if persistent != nil {
+		body["persistent"] = persistent
+	}
+
+	res, err := cl.perform(ctx, "PUT", "/_cluster/settings", nil, body)
+	if err != nil {
+		return err
+	}
+
+	return checkAck(res.Body, "cluster settings update")
+}
+
+// ClusterPendingTasksResponse lists the cluster state changes which have not been executed yet.
+type ClusterPendingTasksResponse struct {
+	Tasks []PendingTask `json:"tasks"`
+}
+
+// PendingTask is a queued cluster state change, e.g. a shard allocation or mapping update.
+type PendingTask struct {
+	InsertOrder int64 `json:"insert_order"`
+	// Priority is one of IMMEDIATE, URGENT, HIGH, NORMAL, LOW and LANGUID.
+	Priority          string `json:"priority"`
+	Source            string `json:"source"`
+	Executing         bool   `json:"executing"`
+	TimeInQueueMillis int64  `json:"time_in_queue_millis"`
+}
+
+// ClusterPendingTasks returns the queue of pending cluster state changes of the cluster behind
+// a registered client. An empty queue means it is safe to start the next maintenance step.
+func ClusterPendingTasks(ctx context.Context, clientName string) (*ClusterPendingTasksResponse, error) {
+	cl, err := getClient(clientName)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := cl.perform(ctx, "GET", "/_cluster/pending_tasks", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := new(ClusterPendingTasksResponse)
+	if err := json.Unmarshal(res.Body, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// ClusterAllocationExplainResponse explains why a shard is unassigned or cannot be moved or rebalanced.
+type ClusterAllocationExplainResponse struct {
+	Index   string `json:"index"`
+	Shard   int    `json:"shard"`
+	Primary bool   `json:"primary"`
+	// CurrentState is e.g. "unassigned" or "started".
+	CurrentState   string `json:"current_state"`
+	UnassignedInfo *struct {
+		Reason               string `json:"reason"`
+		At                   string `json:"at"`
+		LastAllocationStatus string `json:"last_allocation_status"`
+	} `json:"unassigned_info,omitempty"`
+	// CanAllocate is e.g. "no", "yes", "throttled" or "awaiting_info" for unassigned shards.
+	CanAllocate         string `json:"can_allocate"`
+	AllocateExplanation string `json:"allocate_explanation"`
+	// CanRemainOnCurrentNode and CanRebalanceCluster are set for assigned shards.
+	CanRemainOnCurrentNode  string                   `json:"can_remain_on_current_node"`
+	CanRebalanceCluster     string                   `json:"can_rebalance_cluster"`
+	RebalanceExplanation    string                   `json:"rebalance_explanation"`
+	NodeAllocationDecisions []NodeAllocationDecision `json:"node_allocation_decisions"`
+}
+
+// NodeAllocationDecision is the decision whether a shard can be allocated to a node.
+type NodeAllocationDecision struct {
+	NodeID       string `json:"node_id"`
+	NodeName     string `json:"node_name"`
+	NodeDecision string `json:"node_decision"`
+	// Deciders lists the deciders which prevent the allocation along with their explanation.
+	Deciders []struct {
+		Decider     string `json:"decider"`
+		Decision    string `json:"decision"`
+		Explanation string `json:"explanation"`
+	} `json:"deciders"`
+}
+
+// ClusterAllocationExplain explains the allocation of a shard of the cluster behind a registered client.
+// An empty index explains the first unassigned shard elasticsearch finds.
+func ClusterAllocationExplain(ctx context.Context, clientName, index string, shard int, primary bool) (*ClusterAllocationExplainResponse, error) {
+	cl, err := getClient(clientName)
+	if err != nil {
+		return nil, err
+	}
+
+	var body interface{}
+	if index != "" {
+		body = map[string]interface{}{"index": index, "shard": shard, "primary": primary}
+	}
+	res, err := cl.perform(ctx, "GET", "/_cluster/allocation/explain", nil, body)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := new(ClusterAllocationExplainResponse)
+	if err := json.Unmarshal(res.Body, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
@@ -0,0 +1,69 @@
+package eso
+
+import (
+	"context"
+)
+
+// collapseInnerHits is the name of the inner hits requested by Collapse.
+const collapseInnerHits = "collapsed"
+
+// Collapse returns only the top hit per value of field (a keyword or numeric field with doc values).
+// To collapse on a derived value, pass a keyword RuntimeField computing it and collapse on its name.
+// An innerSize > 0 additionally returns up to innerSize hits of every group as inner hits.
+func Collapse(field string, innerSize int) SearchOption {
+	return func(req *searchRequest) error {
+		collapse := map[string]interface{}{"field": field}
+		if innerSize > 0 {
+			collapse["inner_hits"] = map[string]interface{}{"name": collapseInnerHits, "size": innerSize}
+		}
+		req.body["collapse"] = collapse
+		return nil
+	}
+}
+
+// CollapsedGroup is a group of a collapsed search: the top document of the group and a
+// preview of the group's documents (the top document included).
+type CollapsedGroup[T any] struct {
+	// Key is the value of the collapse field.
+	Key   interface{}
+	Top   T
+	Inner []T
+}
+
+// SearchCollapsedTyped searches query collapsed on collapseField and decodes the top document
+// and up to innerSize inner hits of every group into T, e.g. the latest entry per user with a
+// preview of the user's other entries. Meta fields are set like in SearchTyped.
+func SearchCollapsedTyped[T any](ctx context.Context, dt *DocType, query interface{}, collapseField string, innerSize int, opts ...SearchOption) ([]CollapsedGroup[T], error) {
+	opts = append(opts, Collapse(collapseField, innerSize))
+	res, err := dt.search(ctx, []string{dt.readIndex()}, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	if res.Hits == nil {
+		return nil, nil
+	}
+
+	groups := make([]CollapsedGroup[T], 0, len(res.Hits.Hits))
+	for _, hit := range res.Hits.Hits {
+		var group CollapsedGroup[T]
+		if values, ok := hit.Fields[collapseField].([]interface{}); ok && len(values) != 0 {
+			group.Key = values[0]
+		}
+		if err := decodeHit(hit, &group.Top); err != nil {
+			return nil, err
+		}
+
+		for _, inner := range NestedHits(hit, collapseInnerHits) {
+			if inner.Source == nil {
+				continue
+			}
+			var doc T
+			if err := decodeHit(inner, &doc); err != nil {
+				return nil, err
+			}
+			group.Inner = append(group.Inner, doc)
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
@@ -0,0 +1,94 @@
+package eso
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+// defaultDeleteConcurrency is the number of delete by query requests DeleteByQueries runs in parallel by default.
+const defaultDeleteConcurrency = 4
+
+// DeleteByQuery deletes all documents matching query and returns the number of deleted documents.
+// Like in Search, raw json is a request body; only its query part is used.
+func (s *DocType) DeleteByQuery(ctx context.Context, query interface{}) (int64, error) {
+	body, err := searchBody(query)
+	if err != nil {
+		return 0, err
+	}
+	src, ok := body["query"]
+	if !ok {
+		return 0, errors.New("delete by query needs a query")
+	}
+
+	res, err := s.cl.perform(ctx, "POST", s.byQueryPath("_delete_by_query"), nil, map[string]interface{}{"query": src})
+	if err != nil {
+		return 0, err
+	}
+
+	var ret struct {
+		Deleted int64 `json:"deleted"`
+	}
+	if err := json.Unmarshal(res.Body, &ret); err != nil {
+		return 0, err
+	}
+	return ret.Deleted, nil
+}
+
+// DeleteByQueries runs a delete by query for each of queries, concurrency at a time (4 if <= 0),
+// and returns the total number of deleted documents. All queries are run even if some fail;
+// their errors are joined.
+func (s *DocType) DeleteByQueries(ctx context.Context, queries []interface{}, concurrency int) (int64, error) {
+	if concurrency <= 0 {
+		concurrency = defaultDeleteConcurrency
+	}
+
+	var (
+		m       sync.Mutex
+		wg      sync.WaitGroup
+		deleted int64
+		errs    []error
+		sem     = make(chan struct{}, concurrency)
+	)
+	for i, query := range queries {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, query interface{}) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			n, err := s.DeleteByQuery(ctx, query)
+			m.Lock()
+			defer m.Unlock()
+			deleted += n
+			if err != nil {
+				errs = append(errs, fmt.Errorf("query %d: %w", i, err))
+			}
+		}(i, query)
+	}
+	wg.Wait()
+
+	return deleted, errors.Join(errs...)
+}
+
+// ExpireOlderThan deletes all documents whose timestamp field is older than age and returns
+// the number of deleted documents. Run it periodically to expire time series data.
+func (s *DocType) ExpireOlderThan(ctx context.Context, field string, age time.Duration) (int64, error) {
+	before := time.Now().Add(-age).UTC().Format(time.RFC3339Nano)
+	return s.DeleteByQuery(ctx, elastic.NewRangeQuery(field).Lt(before))
+}
+
+// byQueryPath returns the path of a by query endpoint (e.g. _update_by_query) restricted to the doc type.
+func (s *DocType) byQueryPath(endpoint string) string {
+	if s.typeless {
+		return "/" + s.readIndex() + "/" + endpoint
+	}
+	return "/" + s.readIndex() + "/" + s.name + "/" + endpoint
+}
@@ -0,0 +1,86 @@
+package eso
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// Kinds of a FieldChange.
+const (
+	FieldAdded   = "added"
+	FieldRemoved = "removed"
+	FieldChanged = "changed"
+)
+
+// FieldChange is a field which differs between two documents. Path joins nested object
+// fields with "." (e.g. "address.city"); arrays are compared as a whole.
+type FieldChange struct {
+	Path string
+	// Kind is FieldAdded, FieldRemoved or FieldChanged.
+	Kind string
+	Old  interface{}
+	New  interface{}
+}
+
+// DiffDocs compares two json documents and returns the changed fields sorted by path.
+// Either document may be empty. Numbers are compared by their json representation.
+func DiffDocs(old, new json.RawMessage) ([]FieldChange, error) {
+	oldFields, err := decodeDiffDoc(old)
+	if err != nil {
+		return nil, err
+	}
+	newFields, err := decodeDiffDoc(new)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []FieldChange
+	diffFields("", oldFields, newFields, &changes)
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+func decodeDiffDoc(doc json.RawMessage) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if len(bytes.TrimSpace(doc)) == 0 {
+		return fields, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	if err := dec.Decode(&fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func diffFields(prefix string, old, new map[string]interface{}, changes *[]FieldChange) {
+	for name, o := range old {
+		path := prefix + name
+		n, ok := new[name]
+		if !ok {
+			*changes = append(*changes, FieldChange{Path: path, Kind: FieldRemoved, Old: o})
+			continue
+		}
+
+		oo, oldObj := o.(map[string]interface{})
+		no, newObj := n.(map[string]interface{})
+		if oldObj && newObj {
+			diffFields(path+".", oo, no, changes)
+			continue
+		}
+		if !reflect.DeepEqual(o, n) {
+			*changes = append(*changes, FieldChange{Path: path, Kind: FieldChanged, Old: o, New: n})
+		}
+	}
+
+	for name, n := range new {
+		if _, ok := old[name]; !ok {
+			*changes = append(*changes, FieldChange{Path: prefix + name, Kind: FieldAdded, New: n})
+		}
+	}
+}
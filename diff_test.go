@@ -0,0 +1,40 @@
+package eso
+
+import (
+	"fmt"
+	"testing"
+)
+
+var diffDocsTests = []struct {
+	old, new string
+	expected []string
+}{
+	{`{"a": 1, "b": "x"}`, `{"a": 1, "b": "x"}`, nil},
+	{`{"a": 1, "b": "x"}`, `{"a": 2, "c": true}`, []string{"changed a 1 2", "removed b x <nil>", "added c <nil> true"}},
+	{`{"user": {"name": "bob", "age": 3}}`, `{"user": {"name": "alice", "age": 3, "city": "bern"}}`,
+		[]string{"added user.city <nil> bern", "changed user.name bob alice"}},
+	{`{"tags": ["a", "b"], "user": "bob"}`, `{"tags": ["a"], "user": {"name": "bob"}}`,
+		[]string{"changed tags [a b] [a]", "changed user bob map[name:bob]"}},
+	{``, `{"a": 9007199254740993}`, []string{"added a <nil> 9007199254740993"}},
+}
+
+func TestDiffDocs(t *testing.T) {
+	for _, tt := range diffDocsTests {
+		changes, err := DiffDocs([]byte(tt.old), []byte(tt.new))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var actual []string
+		for _, c := range changes {
+			actual = append(actual, fmt.Sprintf("%s %s %v %v", c.Kind, c.Path, c.Old, c.New))
+		}
+		if fmt.Sprint(actual) != fmt.Sprint(tt.expected) {
+			t.Errorf("%s -> %s: expected %v, actual %v", tt.old, tt.new, tt.expected, actual)
+		}
+	}
+
+	if _, err := DiffDocs([]byte(`{"a":`), []byte(`{}`)); err == nil {
+		t.Error("expected error for invalid json")
+	}
+}
@@ -1,69 +1,149 @@
 package eso
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"gopkg.in/olivere/elastic.v5"
 )
 
 var (
+	mu      sync.Mutex
 	clients = map[string]*client{}
-	urls    = map[string]string{}
+	configs = map[string]*clientConfig{}
 )
 
-func RegisterClient(name, url string) {
-	urls[name] = url
+// RegisterClient registers the url of an elasticsearch cluster under name. By default the connection
+// is opened on first use. The error is only set for clients registered with the Eager option.
+func RegisterClient(name, url string, opts ...ClientOption) error {
+	cfg := &clientConfig{url: url}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	mu.Lock()
+	configs[name] = cfg
+	mu.Unlock()
+
+	if !cfg.eager {
+		return nil
+	}
+
+	conn := &client{name: name, url: url, cfg: cfg}
+	if err := conn.newConn(); err != nil {
+		return fmt.Errorf("elasticsearch client %s: %v", name, err)
+	}
+	if err := conn.healthCheck(context.TODO()); err != nil {
+		return fmt.Errorf("elasticsearch client %s: %v", name, err)
+	}
+
+	// a client opened concurrently on first use may already be in use; keep it
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := clients[name]; !ok {
+		clients[name] = conn
+	}
+	return nil
 }
 
 func newClient(name string) *client {
+	conn, err := getClient(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return conn
+}
+
+func getClient(name string) (*client, error) {
+	mu.Lock()
 	conn, ok := clients[name]
 	if !ok {
-		url, ok := urls[name]
+		cfg, ok := configs[name]
 		if !ok {
-			log.Fatal(fmt.Sprintf("unknown elasticsearch client %s", name))
+			mu.Unlock()
+			return nil, fmt.Errorf("unknown elasticsearch client %s", name)
 		}
 
-		conn = &client{name: name, url: url}
+		conn = &client{name: name, url: cfg.url, cfg: cfg}
 		clients[name] = conn
-		conn.checkConn()
 	}
+	mu.Unlock()
 
-	return conn
+	conn.checkConn()
+	return conn, nil
 }
 
 type client struct {
 	name string
 	url  string
+	cfg  *clientConfig
 	conn *elastic.Client
+	http *http.Client
+	once sync.Once
 }
 
+// checkConn opens the connection on first use. Concurrent callers wait for the
+// first one so exactly one connection is created per client.
 func (s *client) checkConn() error {
 	var err error
-	if s.conn == nil {
-		err = s.newConn()
-		if err != nil {
-			log.Fatal(err)
+	s.once.Do(func() {
+		// eager clients are connected on registration
+		if s.conn == nil {
+			err = s.newConn()
 		}
+	})
+	if err != nil {
+		log.Fatal(err)
 	}
 	return err
 }
 
 func (s *client) newConn() error {
 	log.Printf("Opening new Elastic connection to %s called '%s'", s.url, s.name)
+	s.http = &http.Client{Transport: &transport{cfg: s.cfg, next: http.DefaultTransport}}
+	errorLog := log.New(os.Stderr, "ELASTIC ", log.LstdFlags)
+	if s.cfg.quiet {
+		errorLog = log.New(ioutil.Discard, "", log.LstdFlags)
+	}
 	cl, err := elastic.NewSimpleClient(elastic.SetURL(s.url),
-		elastic.SetErrorLog(log.New(os.Stderr, "ELASTIC ", log.LstdFlags)),
+		elastic.SetHttpClient(s.http),
+		elastic.SetErrorLog(errorLog),
 		elastic.SetInfoLog(log.New(ioutil.Discard, "", log.LstdFlags)))
 	s.conn = cl
 	return err
 }
 
+// perform sends a raw request. 429 responses are returned as ErrRateLimited.
+func (s *client) perform(ctx context.Context, method, path string, params url.Values, body interface{}) (*elastic.Response, error) {
+	res, err := s.conn.PerformRequest(ctx, method, path, params, body)
+	return res, rateLimited(err)
+}
+
+// healthCheck makes sure the cluster is reachable and not red.
+func (s *client) healthCheck(ctx context.Context) error {
+	health, err := s.conn.ClusterHealth().Do(ctx)
+	if err != nil {
+		return err
+	}
+	if health.Status == "red" {
+		return errors.New("cluster health is red")
+	}
+	return nil
+}
+
 func NewIndex(name, db string) *Index {
 	cl := newClient(db)
 	return &Index{
@@ -81,10 +161,19 @@ type Index struct {
 	mappings map[string]string
 }
 
+// CheckStructure creates the index with its settings and mappings if it does not exist.
+// If an index template matches the index name, the index is created without a body so
+// the template settings and mappings apply. It is safe to call concurrently for the same index.
 func (s *Index) CheckStructure() error {
 	exists, err := s.indexExists(s.name)
 	if err == nil && !exists {
-		err = s.CreateIndex(s.name)
+		var governed bool
+		governed, err = s.hasMatchingTemplate(context.TODO())
+		if err == nil && governed {
+			err = s.createEmptyIndex(s.name)
+		} else if err == nil {
+			err = s.CreateIndex(s.name)
+		}
 	}
 	if err != nil {
 		log.Fatal(err)
@@ -93,7 +182,8 @@ func (s *Index) CheckStructure() error {
 }
 
 func (s *Index) indexExists(index string) (bool, error) {
-	return s.cl.conn.IndexExists(index).Do(context.TODO())
+	exists, err := s.cl.conn.IndexExists(index).Do(context.TODO())
+	return exists, rateLimited(err)
 }
 
 func (s *Index) AddMapping(docType, mapping string) {
@@ -105,29 +195,106 @@ func (s *Index) AddSetting(key, settings string) {
 }
 
 // CreateIndex creates an index by name. The index specified in the struct is created anyway if it doesnt exist.
+// If the index was created concurrently by someone else, no error is returned. Settings and mappings
+// which are not valid json are reported before anything is sent.
 func (s *Index) CreateIndex(index string) error {
-	body := fmt.Sprintf(`{"settings": %s, "mappings": %s}`,
-		formatMapOfStrings(s.settings),
-		formatMapOfStrings(s.mappings))
+	settings, err := formatMapOfStrings(mergeSettings(s.cl.cfg.defaultSettings, s.settings))
+	if err != nil {
+		return fmt.Errorf("setting %v", err)
+	}
+	mappings, err := formatMapOfStrings(s.mappings)
+	if err != nil {
+		return fmt.Errorf("mapping %v", err)
+	}
+	body := fmt.Sprintf(`{"settings": %s, "mappings": %s}`, settings, mappings)
 
 	createIndex, err := s.cl.conn.CreateIndex(index).Body(body).Do(context.TODO())
+	if alreadyExists(err) {
+		return nil
+	}
+	err = rateLimited(err)
 	if err == nil && !createIndex.Acknowledged {
 		err = errors.New("elasticsearch did not acklowledge new index")
 	}
 	return err
 }
 
+// mergeSettings returns the defaults overridden by the settings of the index.
+func mergeSettings(defaults, settings map[string]string) map[string]string {
+	merged := make(map[string]string, len(defaults)+len(settings))
+	for key, value := range defaults {
+		merged[key] = value
+	}
+	for key, value := range settings {
+		merged[key] = value
+	}
+	return merged
+}
+
 // DeleteIndex deletes the index specified in the struct.
 func (s *Index) DeleteIndex(index string) error {
 	deleteIndex, err := s.cl.conn.DeleteIndex(index).Do(context.TODO())
+	err = rateLimited(err)
 	if err == nil && !deleteIndex.Acknowledged {
 		err = errors.New("elasticsearch did not acklowledge deletion of index")
 	}
 	return err
 }
 
+// DeleteByPattern deletes all indices matching pattern (e.g. "logs-2017.*") and returns their names.
+// Patterns matching all indices ("*", "_all", ...) are refused with ErrDangerousPattern unless force is set.
+func (s *Index) DeleteByPattern(ctx context.Context, pattern string, force bool) ([]string, error) {
+	if !force && dangerousPattern(pattern) {
+		return nil, ErrDangerousPattern
+	}
+
+	params := url.Values{"format": []string{"json"}, "h": []string{"index"}}
+	res, err := s.cl.perform(ctx, "GET", "/_cat/indices/"+pattern, params, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var indices []struct {
+		Index string `json:"index"`
+	}
+	if err := json.Unmarshal(res.Body, &indices); err != nil {
+		return nil, err
+	}
+	if len(indices) == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(indices))
+	for _, idx := range indices {
+		names = append(names, idx.Index)
+	}
+	sort.Strings(names)
+
+	deleteIndex, err := s.cl.conn.DeleteIndex(names...).Do(ctx)
+	err = rateLimited(err)
+	if err == nil && !deleteIndex.Acknowledged {
+		err = errors.New("elasticsearch did not acklowledge deletion of indices")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// dangerousPattern reports whether any part of a comma separated index pattern matches all indices.
+func dangerousPattern(pattern string) bool {
+	for _, part := range strings.Split(pattern, ",") {
+		part = strings.TrimSpace(part)
+		if part == "_all" || strings.Trim(part, "*?") == "" {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *Index) PutIndexTemplate(name string, body string) error {
 	res, err := s.cl.conn.IndexPutTemplate(name).BodyString(body).Do(context.TODO())
+	err = rateLimited(err)
 	if err == nil && !res.Acknowledged {
 		err = errors.New("elasticsearch did not acklowledge creation of template")
 	}
@@ -136,6 +303,7 @@ func (s *Index) PutIndexTemplate(name string, body string) error {
 
 func (s *Index) DeleteIndexTemplate(name string) error {
 	res, err := s.cl.conn.IndexDeleteTemplate(name).Do(context.TODO())
+	err = rateLimited(err)
 	if err == nil && !res.Acknowledged {
 		err = errors.New("elasticsearch did not acklowledge deletion of tempate")
 	}
@@ -149,13 +317,113 @@ func NewDocType(index *Index, name string) *DocType {
 	}
 }
 
+// NewTypelessDocType creates a DocType for elasticsearch 7+ indices which no longer have mapping types.
+// The name is only kept as a logical name: documents are written and read through the typeless
+// _doc endpoints and searches are not restricted to a type. Code written against NewDocType keeps
+// working on older clusters; switch the constructor (e.g. based on ClusterVersion) to migrate.
+func NewTypelessDocType(index *Index, name string) *DocType {
+	return &DocType{
+		Index:    index,
+		name:     name,
+		typeless: true,
+	}
+}
+
+// NewDocTypeAliases creates a DocType which writes documents through writeAlias and reads
+// and searches them through readAlias, the usual split of rollover setups. Note that getting
+// a document by id fails if readAlias points to more than one index.
+func NewDocTypeAliases(index *Index, name, writeAlias, readAlias string) *DocType {
+	return &DocType{
+		Index:      index,
+		name:       name,
+		writeAlias: writeAlias,
+		readAlias:  readAlias,
+	}
+}
+
 type DocType struct {
 	*Index
-	name string
+	name     string
+	typeless bool
+
+	writeAlias string
+	readAlias  string
 }
 
-// IndexDoc creates a document in elasticsearch
-func (s *DocType) IndexDoc(doc interface{}, id string) (string, error) {
+// mappingType returns the type used in document urls.
+func (s *DocType) mappingType() string {
+	if s.typeless {
+		return "_doc"
+	}
+	return s.name
+}
+
+// writeIndex returns the index or alias documents are written to.
+func (s *DocType) writeIndex() string {
+	if s.writeAlias != "" {
+		return s.writeAlias
+	}
+	return s.Index.name
+}
+
+// readIndex returns the index or alias documents are read and searched from.
+func (s *DocType) readIndex() string {
+	if s.readAlias != "" {
+		return s.readAlias
+	}
+	return s.Index.name
+}
+
+// IndexDoc creates a document in elasticsearch. doc can be a json string, an io.Reader
+// streaming the json (for very large documents) or any value to be marshalled.
+func (s *DocType) IndexDoc(doc interface{}, id string, opts ...WriteOption) (string, error) {
+	res, err := s.index(context.TODO(), doc, id, opts)
+	if err != nil {
+		return "", err
+	}
+	return res.Id, err
+}
+
+// IndexResult is the outcome of an index request.
+type IndexResult struct {
+	ID      string
+	Version int64
+	// Result is "created" or "updated".
+	Result           string
+	SuccessfulShards int
+}
+
+// IndexDocResult indexes doc like IndexDoc and returns the version, result and number of
+// shard copies the write reached.
+func (s *DocType) IndexDocResult(ctx context.Context, doc interface{}, id string, opts ...WriteOption) (*IndexResult, error) {
+	res, err := s.index(ctx, doc, id, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &IndexResult{ID: res.Id, Version: res.Version, Result: res.Result}
+	if res.Shards != nil {
+		ret.SuccessfulShards = res.Shards.Successful
+	}
+	return ret, nil
+}
+
+func (s *DocType) index(ctx context.Context, doc interface{}, id string, opts []WriteOption) (*elastic.IndexResponse, error) {
+	params := url.Values{}
+	for _, opt := range opts {
+		opt(params)
+	}
+	params.Del(chunkSizeParam)
+
+	method, path := "POST", fmt.Sprintf("/%s/%s", s.writeIndex(), s.mappingType())
+	if id != "" {
+		method, path = "PUT", path+"/"+url.PathEscape(id)
+	}
+
+	if r, ok := doc.(io.Reader); ok {
+		return s.indexReader(ctx, method, path, params, r)
+	}
+
 	var (
 		body string
 		ok   bool
@@ -163,48 +431,323 @@ func (s *DocType) IndexDoc(doc interface{}, id string) (string, error) {
 	if body, ok = doc.(string); !ok {
 		d, err := json.Marshal(doc)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 		body = string(d)
 	}
 
-	q := s.cl.conn.Index().Index(s.Index.name).Type(s.name).BodyJson(body)
-	if id != "" {
-		q = q.Id(id)
+	res, err := s.cl.perform(ctx, method, path, params, body)
+	if err != nil {
+		return nil, strictMapping(err)
 	}
 
-	res, err := q.Do(context.TODO())
+	ret := new(elastic.IndexResponse)
+	if err := json.Unmarshal(res.Body, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// indexReader streams the document from r to elasticsearch. The elastic client always
+// buffers request bodies, so the request is sent with the underlying http client.
+func (s *DocType) indexReader(ctx context.Context, method, path string, params url.Values, r io.Reader) (*elastic.IndexResponse, error) {
+	u := strings.TrimRight(s.cl.url, "/") + path
+	if len(params) != 0 {
+		u += "?" + params.Encode()
+	}
+
+	req, err := http.NewRequest(method, u, r)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return res.Id, err
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := s.cl.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		e := &elastic.Error{Status: res.StatusCode}
+		json.NewDecoder(res.Body).Decode(e)
+		return nil, strictMapping(rateLimited(e))
+	}
+
+	ret := new(elastic.IndexResponse)
+	if err := json.NewDecoder(res.Body).Decode(ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Update merges partial into the document with the given id. Pass a *Script
+// (NewInlineScript, NewStoredScript) to update the document with a script instead.
+func (s *DocType) Update(ctx context.Context, id string, partial interface{}, opts ...WriteOption) error {
+	body := map[string]interface{}{"doc": partial}
+	switch p := partial.(type) {
+	case string:
+		body["doc"] = json.RawMessage(p)
+	case *Script:
+		body = map[string]interface{}{"script": p}
+	}
+
+	params := url.Values{}
+	for _, opt := range opts {
+		opt(params)
+	}
+	params.Del(chunkSizeParam)
+
+	path := fmt.Sprintf("/%s/%s/%s/_update", s.writeIndex(), s.mappingType(), url.PathEscape(id))
+	_, err := s.cl.perform(ctx, "POST", path, params, body)
+	return strictMapping(err)
 }
 
 // Get retrieves a document from elasticsearch by id
 func (s *DocType) Get(id string) (*elastic.GetResult, error) {
-	res, err := s.cl.conn.Get().Index(s.Index.name).Type(s.name).Id(id).Do(context.TODO())
+	res, err := s.cl.conn.Get().Index(s.readIndex()).Type(s.mappingType()).Id(id).Do(context.TODO())
+	err = rateLimited(err)
 	return res, err
 }
 
+// GetFiltered retrieves a document by id with only the _source fields matching includes
+// and not matching excludes. Both accept wildcards, e.g. "metadata.*" fetches the metadata subtree.
+func (s *DocType) GetFiltered(ctx context.Context, id string, includes, excludes []string) (*elastic.GetResult, error) {
+	q := s.cl.conn.Get().Index(s.readIndex()).Type(s.mappingType()).Id(id)
+	if fsc := fetchSource(includes, excludes); fsc != nil {
+		q = q.FetchSourceContext(fsc)
+	}
+
+	res, err := q.Do(ctx)
+	return res, rateLimited(err)
+}
+
+// fetchSource returns the source filter for includes and excludes or nil if both are empty.
+// Patterns are passed through as is so wildcards are resolved by elasticsearch.
+func fetchSource(includes, excludes []string) *elastic.FetchSourceContext {
+	if len(includes) == 0 && len(excludes) == 0 {
+		return nil
+	}
+	return elastic.NewFetchSourceContext(true).Include(includes...).Exclude(excludes...)
+}
+
+// MultiGet retrieves several documents by id in one request. includes and excludes filter the
+// returned _source fields of every document (wildcards allowed); leave both empty to fetch the whole source.
+func (s *DocType) MultiGet(ctx context.Context, ids []string, includes, excludes []string) ([]*elastic.GetResult, error) {
+	fsc := fetchSource(includes, excludes)
+
+	q := s.cl.conn.MultiGet()
+	for _, id := range ids {
+		item := elastic.NewMultiGetItem().Index(s.readIndex()).Type(s.mappingType()).Id(id)
+		if fsc != nil {
+			item = item.FetchSource(fsc)
+		}
+		q = q.Add(item)
+	}
+
+	res, err := q.Do(ctx)
+	err = rateLimited(err)
+	if err != nil {
+		return nil, err
+	}
+	return res.Docs, nil
+}
+
+// VersionsByIDs returns the current versions of the documents with the given ids without
+// fetching their source. Ids which are not found are missing in the map.
+func (s *DocType) VersionsByIDs(ctx context.Context, ids []string) (map[string]int64, error) {
+	q := s.cl.conn.MultiGet()
+	for _, id := range ids {
+		q = q.Add(elastic.NewMultiGetItem().Index(s.readIndex()).Type(s.mappingType()).Id(id).
+			FetchSource(elastic.NewFetchSourceContext(false)))
+	}
+
+	res, err := q.Do(ctx)
+	if err != nil {
+		return nil, rateLimited(err)
+	}
+
+	versions := make(map[string]int64, len(res.Docs))
+	for _, doc := range res.Docs {
+		if doc == nil || !doc.Found || doc.Version == nil {
+			continue
+		}
+		versions[doc.Id] = *doc.Version
+	}
+	return versions, nil
+}
+
+// SeqNoResult is a GetResult including the sequence number and primary term of the document.
+type SeqNoResult struct {
+	elastic.GetResult
+	SeqNo       int64 `json:"_seq_no"`
+	PrimaryTerm int64 `json:"_primary_term"`
+}
+
+// GetSeqNo retrieves a document by id along with its sequence number and primary term
+// (elasticsearch 6.7+). Pass them to IfSeqNo when writing the document back.
+func (s *DocType) GetSeqNo(ctx context.Context, id string) (*SeqNoResult, error) {
+	path := fmt.Sprintf("/%s/%s/%s", s.readIndex(), s.mappingType(), url.PathEscape(id))
+	res, err := s.cl.perform(ctx, "GET", path, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := new(SeqNoResult)
+	if err := json.Unmarshal(res.Body, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// CopyTo copies the document with the given id into dest. An empty newID keeps the id.
+// Returns ErrNotFound if the document does not exist.
+func (s *DocType) CopyTo(ctx context.Context, id string, dest *DocType, newID string) error {
+	res, err := s.cl.conn.Get().Index(s.readIndex()).Type(s.mappingType()).Id(id).Do(ctx)
+	err = rateLimited(err)
+	if elastic.IsNotFound(err) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if !res.Found || res.Source == nil {
+		return ErrNotFound
+	}
+
+	if newID == "" {
+		newID = id
+	}
+	_, err = dest.index(ctx, string(*res.Source), newID, nil)
+	return err
+}
+
+// GetAfterWrite polls for the document with the given id until it is found or timeout elapses.
+// Returns ErrNotFound if the document did not appear in time and the error of ctx if ctx is done.
+func (s *DocType) GetAfterWrite(ctx context.Context, id string, timeout time.Duration) (*elastic.GetResult, error) {
+	pollCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		res, err := s.cl.conn.Get().Index(s.readIndex()).Type(s.mappingType()).Id(id).Do(pollCtx)
+		if err == nil && res.Found {
+			return res, nil
+		}
+		if err != nil && !elastic.IsNotFound(err) && pollCtx.Err() == nil {
+			return nil, rateLimited(err)
+		}
+
+		select {
+		case <-pollCtx.Done():
+			// only the timeout means the document did not appear
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			return nil, ErrNotFound
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// GetOrCreate fills target with the document with the given id. If it does not exist, defaultDoc
+// is created (op_type create) and filled into target instead. If another caller creates the
+// document at the same time, the conflict is resolved by reading the stored document.
+func (s *DocType) GetOrCreate(ctx context.Context, id string, defaultDoc interface{}, target interface{}) (created bool, err error) {
+	err = s.fill(ctx, id, target)
+	if err != ErrNotFound {
+		return false, err
+	}
+
+	_, err = s.index(ctx, defaultDoc, id, []WriteOption{CreateOnly()})
+	if elastic.IsConflict(err) {
+		return false, s.fill(ctx, id, target)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	doc, ok := defaultDoc.(string)
+	if !ok {
+		d, err := json.Marshal(defaultDoc)
+		if err != nil {
+			return true, err
+		}
+		doc = string(d)
+	}
+	return true, decodeSource([]byte(doc), target)
+}
+
+// GetMap retrieves the source of the document with the given id as a map. Numbers are kept
+// as json.Number so 64 bit integers do not lose precision. Returns ErrNotFound if the
+// document does not exist.
+func (s *DocType) GetMap(ctx context.Context, id string) (map[string]interface{}, error) {
+	var doc map[string]interface{}
+	if err := s.fill(ctx, id, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// decodeSource unmarshals a document source into target. Numbers decoded into interface{}
+// values become json.Number instead of float64 to keep integers above 2^53 exact.
+func decodeSource(src []byte, target interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(src))
+	dec.UseNumber()
+	return dec.Decode(target)
+}
+
+// fill unmarshals the source of the document with the given id into target.
+func (s *DocType) fill(ctx context.Context, id string, target interface{}) error {
+	res, err := s.cl.conn.Get().Index(s.readIndex()).Type(s.mappingType()).Id(id).Do(ctx)
+	if elastic.IsNotFound(err) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return rateLimited(err)
+	}
+	if !res.Found {
+		return ErrNotFound
+	}
+	if res.Source == nil {
+		return ErrSourceDisabled
+	}
+	return decodeSource(*res.Source, target)
+}
+
 // Delete removes one document from elasticsearch by id
 func (s *DocType) Delete(id string) (bool, error) {
-	res, err := s.cl.conn.Delete().Index(s.Index.name).Type(s.name).Id(id).Do(context.TODO())
+	res, err := s.cl.conn.Delete().Index(s.writeIndex()).Type(s.mappingType()).Id(id).Do(context.TODO())
+	err = rateLimited(err)
 	return res.Found, err
 }
 
-// Search takes a json search string and executes it, returning the result
-func (s *DocType) Search(json interface{}) (*elastic.SearchResult, error) {
-	return s.cl.conn.Search(s.Index.name).Source(json).Pretty(true).Do(context.TODO())
+// Search takes a json search string (or an elastic.Query) and executes it, returning the result
+func (s *DocType) Search(json interface{}, opts ...SearchOption) (*elastic.SearchResult, error) {
+	return s.search(context.TODO(), []string{s.readIndex()}, json, opts)
 }
 
-func formatMapOfStrings(m map[string]string) string {
-	s := fmt.Sprintf("%#v", m)
-	s = strings.Replace(s, "\\\"", "\"", -1)
-	s = strings.Replace(s, "\"{", "{", -1)
-	s = strings.Replace(s, "}\"", "}", -1)
-	s = strings.Replace(s, "\\n", "", -1)
-	s = strings.Replace(s, "\\t", "", -1)
-	return strings.Trim(s, "map[string]")
+// formatMapOfStrings joins the json values of m into a json object. Plain values which are not
+// json (e.g. 5s) are added as json strings; values looking like json objects or arrays but not
+// being valid json are an error naming the key.
+func formatMapOfStrings(m map[string]string) (string, error) {
+	raw := make(map[string]json.RawMessage, len(m))
+	for key, value := range m {
+		if json.Valid([]byte(value)) {
+			raw[key] = json.RawMessage(value)
+			continue
+		}
+		if v := strings.TrimSpace(value); strings.HasPrefix(v, "{") || strings.HasPrefix(v, "[") {
+			var x interface{}
+			return "", fmt.Errorf("%s is not valid json: %v", key, json.Unmarshal([]byte(v), &x))
+		}
+		str, _ := json.Marshal(value)
+		raw[key] = str
+	}
+
+	// values are valid json, so marshalling can not fail
+	b, _ := json.Marshal(raw)
+	return string(b), nil
 }
 
 func NewDoc(docType *DocType) *Doc {
@@ -227,17 +770,16 @@ func (s *Doc) Save(doc interface{}) error {
 	return nil
 }
 
-func (s *Doc) FillByID(target interface{}, id string) error {
-	res, err := s.DocType.Get(id)
-	if err != nil {
-		return err
-	}
-
-	if res.Source == nil {
-		return errors.New("empty source returned")
+// Patch merges partial into the saved document. Unlike Save it only changes the given fields.
+func (s *Doc) Patch(partial interface{}) error {
+	if s.ID == "" {
+		return errors.New("cannot patch a document without id")
 	}
+	return s.DocType.Update(context.TODO(), s.ID, partial)
+}
 
-	return json.Unmarshal([]byte(*res.Source), target)
+func (s *Doc) FillByID(target interface{}, id string) error {
+	return s.DocType.fill(context.TODO(), id, target)
 }
 
 func (s *Doc) Delete() (bool, error) {
@@ -1,8 +1,17 @@
 package eso
 
 import (
+	"context"
+	"encoding/json"
 	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
 	"testing"
+
+	"gopkg.in/olivere/elastic.v5"
 )
 
 var indicesTests = []struct {
@@ -51,6 +60,96 @@ func TestDeleteIndex(t *testing.T) {
 	}
 }
 
+func TestCheckStructureConcurrent(t *testing.T) {
+	var created int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "HEAD":
+			w.WriteHeader(http.StatusNotFound)
+		case r.URL.Path == "/_template":
+			w.Write([]byte(`{}`))
+		case r.Method == "PUT" && atomic.AddInt32(&created, 1) == 1:
+			w.Write([]byte(`{"acknowledged": true}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": {"type": "resource_already_exists_exception", "reason": "index [unit_race] already exists"}, "status": 400}`))
+		}
+	}))
+	defer srv.Close()
+
+	RegisterClient("race_test", srv.URL)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- NewIndex("unit_race", "race_test").CheckStructure()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if atomic.LoadInt32(&created) != 10 {
+		t.Error("expected every goroutine to try creating the index", created)
+	}
+}
+
+var dangerousPatternTests = []struct {
+	pattern  string
+	expected bool
+}{
+	{"*", true},
+	{"_all", true},
+	{"", true},
+	{"**", true},
+	{"logs-*,*", true},
+	{" * ", true},
+	{"logs-*", false},
+	{"*-archive", false},
+	{"logs-2017.*,mails-2017.*", false},
+}
+
+func TestDangerousPattern(t *testing.T) {
+	for _, tt := range dangerousPatternTests {
+		if actual := dangerousPattern(tt.pattern); actual != tt.expected {
+			t.Errorf("dangerousPattern(%q): expected %v, actual %v", tt.pattern, tt.expected, actual)
+		}
+	}
+}
+
+var idempotentTests = []struct {
+	method   string
+	path     string
+	expected bool
+}{
+	{"GET", "/unit_test/_search", true},
+	{"HEAD", "/unit_test", true},
+	{"PUT", "/unit_test/test/1", true},
+	{"POST", "/unit_test/test/1", true},
+	{"POST", "/unit_test/test/1/_update", true},
+	{"POST", "/unit_test/_update/1", true},
+	{"POST", "/unit_test/test", false},
+	{"POST", "/unit_test/test/_search", false},
+	{"POST", "/_bulk", false},
+	{"DELETE", "/unit_test/test/_delete_by_query", false},
+}
+
+func TestIdempotent(t *testing.T) {
+	for _, tt := range idempotentTests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if actual := idempotent(req); actual != tt.expected {
+			t.Errorf("idempotent(%s %s): expected %v, actual %v", tt.method, tt.path, tt.expected, actual)
+		}
+	}
+}
+
 var indexingTests = []struct {
 	doc      interface{}
 	id       string
@@ -94,6 +193,81 @@ func TestGet(t *testing.T) {
 	}
 }
 
+var sourceFilterTests = []struct {
+	includes []string
+	excludes []string
+	expected string
+}{
+	{[]string{"metadata.*"}, nil, `{"metadata":{"a":{"b":1},"c":"x"}}`},
+	{[]string{"metadata.a.*"}, nil, `{"metadata":{"a":{"b":1}}}`},
+	{[]string{"metadata.*"}, []string{"*.c"}, `{"metadata":{"a":{"b":1}}}`},
+}
+
+func TestSourceFilter(t *testing.T) {
+	ind := NewIndex("unit_test", "local")
+	doc := NewDocType(ind, "test")
+	if _, err := doc.IndexDoc(`{"test": "nested", "metadata": {"a": {"b": 1}, "c": "x"}, "other": {"d": 2}}`, "filtered"); err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Delete("filtered")
+	if _, err := ind.cl.conn.Refresh("unit_test").Do(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range sourceFilterTests {
+		res, err := doc.GetFiltered(context.TODO(), "filtered", tt.includes, tt.excludes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !jsonEqual(*res.Source, tt.expected) {
+			t.Error(string(*res.Source), tt.expected)
+		}
+
+		search, err := doc.Search(elastic.NewIdsQuery().Ids("filtered"), SourceFilter(tt.includes, tt.excludes))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(search.Hits.Hits) != 1 || !jsonEqual(*search.Hits.Hits[0].Source, tt.expected) {
+			t.Error(search.Hits.Hits, tt.expected)
+		}
+	}
+}
+
+func TestDecodeSource(t *testing.T) {
+	// 2^53 + 1 is the first integer a float64 can not represent
+	src := []byte(`{"id": 9007199254740993, "nested": {"counter": 9007199254740995}, "ratio": 0.5}`)
+
+	var doc map[string]interface{}
+	if err := decodeSource(src, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if n, ok := doc["id"].(json.Number); !ok || n.String() != "9007199254740993" {
+		t.Error(doc["id"])
+	}
+	nested, _ := doc["nested"].(map[string]interface{})
+	if n, ok := nested["counter"].(json.Number); !ok || n.String() != "9007199254740995" {
+		t.Error(nested["counter"])
+	}
+
+	var typed struct {
+		ID    int64   `json:"id"`
+		Ratio float64 `json:"ratio"`
+	}
+	if err := decodeSource(src, &typed); err != nil {
+		t.Fatal(err)
+	}
+	if typed.ID != 9007199254740993 || typed.Ratio != 0.5 {
+		t.Error(typed)
+	}
+}
+
+func jsonEqual(a []byte, b string) bool {
+	var va, vb interface{}
+	json.Unmarshal(a, &va)
+	json.Unmarshal([]byte(b), &vb)
+	return reflect.DeepEqual(va, vb)
+}
+
 var searchTests = []struct {
 	json     string
 	expected int64
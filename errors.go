@@ -0,0 +1,133 @@
+package eso
+
+import (
+	"errors"
+	"net/http"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+var (
+	// ErrNotFound is returned when a requested document does not exist.
+	ErrNotFound = errors.New("document not found")
+	// ErrSourceDisabled is returned when a document exists but its _source is not stored.
+	ErrSourceDisabled = errors.New("document found but _source is disabled")
+	// ErrRateLimited matches (errors.Is) errors of requests elasticsearch rejected with
+	// 429 too many requests (es_rejected_execution_exception). Back off before retrying.
+	ErrRateLimited = errors.New("elasticsearch rejected the request: too many requests")
+	// ErrDangerousPattern is returned by DeleteByPattern for patterns matching all indices unless forced.
+	ErrDangerousPattern = errors.New("index pattern matches all indices")
+	// ErrScrollExpired is returned by ScrollCursor.Next if the scroll context was freed on the server
+	// because the keep alive elapsed between two batches.
+	ErrScrollExpired = errors.New("scroll context expired")
+	// ErrStrictMapping matches (errors.Is) errors of writes elasticsearch rejected because the document
+	// has fields not in a mapping with dynamic: strict (strict_dynamic_mapping_exception).
+	ErrStrictMapping = errors.New("document has fields not allowed by the strict mapping")
+)
+
+// rateLimitedError wraps a 429 error of the elastic client. It matches ErrRateLimited
+// while errors.As still finds the underlying *elastic.Error.
+type rateLimitedError struct {
+	err error
+}
+
+func (s *rateLimitedError) Error() string {
+	return s.err.Error()
+}
+
+func (s *rateLimitedError) Unwrap() error {
+	return s.err
+}
+
+func (s *rateLimitedError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// rateLimited wraps err if it is a 429 response and returns it unchanged otherwise.
+func rateLimited(err error) error {
+	var e *elastic.Error
+	if errors.As(err, &e) && e.Status == http.StatusTooManyRequests {
+		return &rateLimitedError{err: err}
+	}
+	return err
+}
+
+// strictMappingError wraps a strict_dynamic_mapping_exception error of the elastic client.
+// It matches ErrStrictMapping while errors.As still finds the underlying *elastic.Error.
+type strictMappingError struct {
+	err error
+}
+
+func (s *strictMappingError) Error() string {
+	return s.err.Error()
+}
+
+func (s *strictMappingError) Unwrap() error {
+	return s.err
+}
+
+func (s *strictMappingError) Is(target error) bool {
+	return target == ErrStrictMapping
+}
+
+// strictMapping wraps err if it is a strict_dynamic_mapping_exception and returns it unchanged otherwise.
+func strictMapping(err error) error {
+	var e *elastic.Error
+	if !errors.As(err, &e) || e.Details == nil {
+		return err
+	}
+	if e.Details.Type == "strict_dynamic_mapping_exception" {
+		return &strictMappingError{err: err}
+	}
+	for _, cause := range e.Details.RootCause {
+		if cause != nil && cause.Type == "strict_dynamic_mapping_exception" {
+			return &strictMappingError{err: err}
+		}
+	}
+	return err
+}
+
+// alreadyExists reports whether err is elasticsearch refusing to create an index which already exists.
+func alreadyExists(err error) bool {
+	var e *elastic.Error
+	if !errors.As(err, &e) || e.Details == nil {
+		return false
+	}
+	// elasticsearch 5 uses index_already_exists_exception
+	return e.Details.Type == "resource_already_exists_exception" || e.Details.Type == "index_already_exists_exception"
+}
+
+// scrollExpiredError wraps the error of a scroll whose context is gone. It matches
+// ErrScrollExpired while errors.As still finds the underlying *elastic.Error.
+type scrollExpiredError struct {
+	err error
+}
+
+func (s *scrollExpiredError) Error() string {
+	return ErrScrollExpired.Error() + ": " + s.err.Error()
+}
+
+func (s *scrollExpiredError) Unwrap() error {
+	return s.err
+}
+
+func (s *scrollExpiredError) Is(target error) bool {
+	return target == ErrScrollExpired
+}
+
+// scrollExpired reports whether err is elasticsearch not finding the context of a scroll.
+func scrollExpired(err error) bool {
+	var e *elastic.Error
+	if !errors.As(err, &e) || e.Details == nil {
+		return false
+	}
+	if e.Details.Type == "search_context_missing_exception" {
+		return true
+	}
+	for _, cause := range e.Details.RootCause {
+		if cause != nil && cause.Type == "search_context_missing_exception" {
+			return true
+		}
+	}
+	return false
+}
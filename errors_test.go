@@ -0,0 +1,68 @@
+package eso
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+var rateLimitedTests = []struct {
+	err         error
+	rateLimited bool
+}{
+	{&elastic.Error{Status: 429}, true},
+	{fmt.Errorf("search: %w", &elastic.Error{Status: 429}), true},
+	{&elastic.Error{Status: 404}, false},
+	{&elastic.Error{Status: 400}, false},
+	{errors.New("connection refused"), false},
+	{&BulkError{Items: []BulkItemError{{Status: 400}, {Status: 429}}}, true},
+	{&BulkError{Items: []BulkItemError{{Status: 400}}}, false},
+}
+
+func TestRateLimited(t *testing.T) {
+	for _, tt := range rateLimitedTests {
+		err := rateLimited(tt.err)
+		if errors.Is(err, ErrRateLimited) != tt.rateLimited {
+			t.Error(tt.err, tt.rateLimited)
+		}
+
+		var e *elastic.Error
+		if errors.As(tt.err, &e) && !errors.As(err, &e) {
+			t.Error("wrapped error hides *elastic.Error", tt.err)
+		}
+	}
+}
+
+var strictMappingTests = []struct {
+	err    error
+	strict bool
+}{
+	{&elastic.Error{Status: 400, Details: &elastic.ErrorDetails{Type: "strict_dynamic_mapping_exception"}}, true},
+	{&elastic.Error{Status: 400, Details: &elastic.ErrorDetails{Type: "mapper_parsing_exception"}}, false},
+	{&elastic.Error{Status: 400}, false},
+	{&BulkError{Items: []BulkItemError{{Status: 400, Type: "strict_dynamic_mapping_exception"}}}, true},
+	{&BulkError{Items: []BulkItemError{{Status: 429}}}, false},
+}
+
+func TestStrictMapping(t *testing.T) {
+	for _, tt := range strictMappingTests {
+		if errors.Is(strictMapping(tt.err), ErrStrictMapping) != tt.strict {
+			t.Error(tt.err, tt.strict)
+		}
+	}
+}
+
+func TestScrollExpiredError(t *testing.T) {
+	cause := &elastic.Error{Status: 404, Details: &elastic.ErrorDetails{Type: "search_context_missing_exception"}}
+	err := fmt.Errorf("next: %w", &scrollExpiredError{err: cause})
+
+	if !errors.Is(err, ErrScrollExpired) {
+		t.Error("expected ErrScrollExpired", err)
+	}
+	var e *elastic.Error
+	if !errors.As(err, &e) || e.Status != 404 {
+		t.Error("wrapped error hides *elastic.Error", err)
+	}
+}
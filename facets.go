@@ -0,0 +1,100 @@
+package eso
+
+import (
+	"context"
+	"sort"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+// FacetResult holds the hits of a faceted search and the value counts of every facet field.
+type FacetResult struct {
+	Hits *elastic.SearchHits
+	// Facets holds the counts keyed by facet field, ordered by count descending.
+	Facets map[string][]FacetCount
+}
+
+// FacetCount is the number of documents having a value in a facet field.
+type FacetCount struct {
+	Value string
+	Count int64
+}
+
+// Facets searches query and counts the top size values of every facet field in one round trip.
+// selected holds the filters of the facet values the user selected keyed by facet field. They
+// filter the hits (post_filter) and the counts of all other facets, but not the counts of their
+// own facet, so the alternatives of a selected value stay visible with their counts.
+func (s *DocType) Facets(ctx context.Context, query interface{}, selected map[string]interface{}, fields []string, size int, opts ...SearchOption) (*FacetResult, error) {
+	filters := make(map[string]interface{}, len(selected))
+	for field, filter := range selected {
+		src, err := querySource(filter)
+		if err != nil {
+			return nil, err
+		}
+		filters[field] = src
+	}
+
+	opts = append(opts, func(req *searchRequest) error {
+		if len(filters) != 0 {
+			req.body["post_filter"] = facetFilter(filters, "")
+		}
+
+		aggs := make(map[string]interface{}, len(fields))
+		for _, field := range fields {
+			aggs[field] = map[string]interface{}{
+				"filter": facetFilter(filters, field),
+				"aggs": map[string]interface{}{
+					"values": map[string]interface{}{
+						"terms": map[string]interface{}{"field": field, "size": size},
+					},
+				},
+			}
+		}
+		req.body["aggs"] = aggs
+		return nil
+	})
+
+	res, err := s.search(ctx, []string{s.readIndex()}, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := DecodeAggregations(res.Aggregations)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &FacetResult{Hits: res.Hits, Facets: make(map[string][]FacetCount, len(fields))}
+	for _, field := range fields {
+		counts := []FacetCount{}
+		if node, ok := nodes[field]; ok && len(node.Buckets) == 1 {
+			if values := node.Buckets[0].Agg("values"); values != nil {
+				for _, b := range values.Buckets {
+					counts = append(counts, FacetCount{Value: b.Key, Count: b.DocCount})
+				}
+			}
+		}
+		ret.Facets[field] = counts
+	}
+	return ret, nil
+}
+
+// facetFilter combines the filters of all facets except the one of field exclude.
+// The filters are sorted by field to send identical requests for identical selections.
+func facetFilter(filters map[string]interface{}, exclude string) interface{} {
+	fields := make([]string, 0, len(filters))
+	for field := range filters {
+		if field != exclude {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	list := make([]interface{}, 0, len(fields))
+	for _, field := range fields {
+		list = append(list, filters[field])
+	}
+	if len(list) == 0 {
+		return map[string]interface{}{"match_all": map[string]interface{}{}}
+	}
+	return map[string]interface{}{"bool": map[string]interface{}{"filter": list}}
+}
@@ -0,0 +1,36 @@
+package eso
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+var facetFilterTests = []struct {
+	exclude  string
+	expected string
+}{
+	{"", `{"bool":{"filter":[{"term":{"brand":"acme"}},{"term":{"color":"red"}}]}}`},
+	{"brand", `{"bool":{"filter":[{"term":{"color":"red"}}]}}`},
+	{"color", `{"bool":{"filter":[{"term":{"brand":"acme"}}]}}`},
+}
+
+func TestFacetFilter(t *testing.T) {
+	filters := map[string]interface{}{
+		"color": map[string]interface{}{"term": map[string]interface{}{"color": "red"}},
+		"brand": map[string]interface{}{"term": map[string]interface{}{"brand": "acme"}},
+	}
+	for _, tt := range facetFilterTests {
+		b, err := json.Marshal(facetFilter(filters, tt.exclude))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.expected {
+			t.Errorf("exclude %q: got %s, expected %s", tt.exclude, b, tt.expected)
+		}
+	}
+
+	b, _ := json.Marshal(facetFilter(map[string]interface{}{}, ""))
+	if string(b) != `{"match_all":{}}` {
+		t.Errorf("got %s for no filters", b)
+	}
+}
@@ -0,0 +1,23 @@
+package eso
+
+import "gopkg.in/olivere/elastic.v5"
+
+// GeoDistanceFilter matches documents with a geo_point field within distance (e.g. "12km") of lat/lon.
+func GeoDistanceFilter(field string, lat, lon float64, distance string) elastic.Query {
+	return elastic.NewGeoDistanceQuery(field).Lat(lat).Lon(lon).Distance(distance)
+}
+
+// SortByGeoDistance sorts the hits by their distance (in unit, e.g. "km") to lat/lon, nearest first.
+// Use HitDistance to read the distance of a hit.
+func SortByGeoDistance(field string, lat, lon float64, unit string) SearchOption {
+	return SortBy(elastic.NewGeoDistanceSort(field).Point(lat, lon).Unit(unit).Asc())
+}
+
+// HitDistance returns the distance computed by SortByGeoDistance if it was the first sort of the search.
+func HitDistance(hit *elastic.SearchHit) (float64, bool) {
+	if len(hit.Sort) == 0 {
+		return 0, false
+	}
+	d, ok := hit.Sort[0].(float64)
+	return d, ok
+}
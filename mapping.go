@@ -0,0 +1,422 @@
+package eso
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GetMapping returns the mappings of the index as returned by elasticsearch
+// (keyed by mapping type for typed indices).
+func (s *Index) GetMapping(ctx context.Context) (map[string]interface{}, error) {
+	res, err := s.cl.conn.GetMapping().Index(s.name).Do(ctx)
+	err = rateLimited(err)
+	if err != nil {
+		return nil, err
+	}
+
+	idx, ok := res[s.name].(map[string]interface{})
+	if !ok {
+		// s.name is an alias: take the first index behind it
+		for _, v := range res {
+			idx, _ = v.(map[string]interface{})
+			break
+		}
+	}
+	mappings, _ := idx["mappings"].(map[string]interface{})
+	return mappings, nil
+}
+
+// SetDynamic sets how the mapping of the doc type treats fields it does not know: "true" adds them,
+// "false" ignores them and "strict" rejects the document. Writes rejected by a strict mapping
+// return errors matching ErrStrictMapping. Objects mapped with their own dynamic setting keep it.
+func (s *DocType) SetDynamic(ctx context.Context, mode string) error {
+	path := "/" + s.Index.name + "/_mapping"
+	if !s.typeless {
+		path += "/" + s.name
+	}
+
+	res, err := s.cl.perform(ctx, "PUT", path, nil, map[string]interface{}{"dynamic": mode})
+	if err != nil {
+		return err
+	}
+
+	return checkAck(res.Body, "mapping update")
+}
+
+// fieldTypes returns the mapped type of every field of the doc type keyed by its dotted path.
+func (s *DocType) fieldTypes(ctx context.Context) (map[string]string, error) {
+	mappings, err := s.Index.GetMapping(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mapping := mappings
+	if !s.typeless {
+		mapping, _ = mappings[s.name].(map[string]interface{})
+	}
+
+	types := map[string]string{}
+	flattenMapping("", mapping, types)
+	return types, nil
+}
+
+func flattenMapping(prefix string, mapping map[string]interface{}, types map[string]string) {
+	props, _ := mapping["properties"].(map[string]interface{})
+	for name, p := range props {
+		field, _ := p.(map[string]interface{})
+		path := prefix + name
+
+		typ, _ := field["type"].(string)
+		if typ == "" {
+			typ = "object"
+		}
+		types[path] = typ
+
+		if _, ok := field["properties"]; ok {
+			flattenMapping(path+".", field, types)
+		}
+	}
+}
+
+// MappingConflictError lists the fields of a document whose values do not fit the live mapping.
+type MappingConflictError struct {
+	Conflicts []string
+}
+
+func (s *MappingConflictError) Error() string {
+	return "document does not fit the mapping: " + strings.Join(s.Conflicts, "; ")
+}
+
+// CheckMapping validates a sample document against the live mapping of the doc type
+// before indexing it. Fields which are not mapped yet are ignored. Conflicting fields
+// are reported as *MappingConflictError.
+func (s *DocType) CheckMapping(ctx context.Context, doc interface{}) error {
+	types, err := s.fieldTypes(ctx)
+	if err != nil {
+		return err
+	}
+
+	conflicts, err := mappingConflicts(types, doc)
+	if err != nil {
+		return err
+	}
+	if len(conflicts) != 0 {
+		return &MappingConflictError{Conflicts: conflicts}
+	}
+	return nil
+}
+
+func mappingConflicts(types map[string]string, doc interface{}) ([]string, error) {
+	var d []byte
+	switch v := doc.(type) {
+	case string:
+		d = []byte(v)
+	case []byte:
+		d = v
+	default:
+		var err error
+		if d, err = json.Marshal(doc); err != nil {
+			return nil, err
+		}
+	}
+
+	var fields map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(d))
+	dec.UseNumber()
+	if err := dec.Decode(&fields); err != nil {
+		return nil, err
+	}
+
+	var conflicts []string
+	for name, v := range fields {
+		checkFieldValue(name, v, types, &conflicts)
+	}
+	return conflicts, nil
+}
+
+func checkFieldValue(path string, v interface{}, types map[string]string, conflicts *[]string) {
+	typ, mapped := types[path]
+
+	switch val := v.(type) {
+	case []interface{}:
+		for _, el := range val {
+			checkFieldValue(path, el, types, conflicts)
+		}
+	case map[string]interface{}:
+		if mapped {
+			switch typ {
+			case "object", "nested":
+			case "geo_point", "geo_shape", "integer_range", "long_range", "float_range", "double_range", "date_range", "ip_range":
+				return
+			default:
+				*conflicts = append(*conflicts, fmt.Sprintf("field %s: object value does not fit mapped type %s", path, typ))
+				return
+			}
+		}
+		for name, sub := range val {
+			checkFieldValue(path+"."+name, sub, types, conflicts)
+		}
+	default:
+		if mapped && !valueFitsType(typ, val) {
+			*conflicts = append(*conflicts, fmt.Sprintf("field %s: value %v does not fit mapped type %s", path, val, typ))
+		}
+	}
+}
+
+func valueFitsType(typ string, v interface{}) bool {
+	if v == nil {
+		return true
+	}
+
+	switch typ {
+	case "object", "nested":
+		return false
+	case "long", "integer", "short", "byte", "double", "float", "half_float", "scaled_float":
+		switch val := v.(type) {
+		case json.Number:
+			return true
+		case string:
+			_, err := strconv.ParseFloat(val, 64)
+			return err == nil
+		}
+		return false
+	case "boolean":
+		switch val := v.(type) {
+		case bool:
+			return true
+		case string:
+			return val == "true" || val == "false" || val == ""
+		}
+		return false
+	case "date":
+		_, isBool := v.(bool)
+		return !isBool
+	}
+	return true
+}
+
+// MappingMismatch is a field of a Go struct which does not match the live mapping.
+type MappingMismatch struct {
+	Field string
+	// Expected lists the mapping types fitting the Go type.
+	Expected []string
+	// Actual is the mapped type, empty if the field is not mapped.
+	Actual string
+}
+
+func (s MappingMismatch) String() string {
+	if s.Actual == "" {
+		return fmt.Sprintf("field %s: not mapped, expected %s", s.Field, strings.Join(s.Expected, "|"))
+	}
+	return fmt.Sprintf("field %s: mapped as %s, expected %s", s.Field, s.Actual, strings.Join(s.Expected, "|"))
+}
+
+// ValidateStruct compares the fields of the struct v (by json name) against the live mapping
+// and returns the fields which are missing in the mapping or mapped with a type not fitting
+// the Go type. Use it as a startup self-check to catch drift between structs and mappings.
+func (s *DocType) ValidateStruct(ctx context.Context, v interface{}) ([]MappingMismatch, error) {
+	t, err := structType(v)
+	if err != nil {
+		return nil, err
+	}
+	types, err := s.fieldTypes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return structMismatches(t, types), nil
+}
+
+// structType returns the struct type of v, which may be a struct or a pointer to one.
+func structType(v interface{}) (reflect.Type, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%T is not a struct", v)
+	}
+	return t, nil
+}
+
+func structMismatches(t reflect.Type, types map[string]string) []MappingMismatch {
+	var mismatches []MappingMismatch
+	walkStruct("", t, map[reflect.Type]bool{}, func(path string, expected []string) {
+		actual := types[path]
+		for _, typ := range expected {
+			if typ == actual {
+				return
+			}
+		}
+		mismatches = append(mismatches, MappingMismatch{Field: path, Expected: expected, Actual: actual})
+	})
+
+	sort.Slice(mismatches, func(i, j int) bool { return mismatches[i].Field < mismatches[j].Field })
+	return mismatches
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(json.RawMessage{})
+)
+
+// walkStruct calls fn with the json path and the fitting mapping types of every field of t.
+// onPath holds the struct types being walked; recursive types are not descended into again.
+func walkStruct(prefix string, t reflect.Type, onPath map[reflect.Type]bool, fn func(path string, expected []string)) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || onPath[t] {
+		return
+	}
+	onPath[t] = true
+	defer delete(onPath, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+
+		name := f.Name
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		if n := strings.Split(tag, ",")[0]; n != "" {
+			name = n
+		} else if f.Anonymous {
+			// embedded structs without json name are flattened
+			walkStruct(prefix, f.Type, onPath, fn)
+			continue
+		}
+		path := prefix + name
+
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft == rawType {
+			continue
+		}
+		if (ft.Kind() == reflect.Slice || ft.Kind() == reflect.Array) && ft.Elem().Kind() != reflect.Uint8 {
+			ft = ft.Elem()
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+		}
+
+		switch {
+		case ft == timeType:
+			fn(path, []string{"date"})
+		case ft.Kind() == reflect.Struct:
+			fn(path, []string{"object", "nested"})
+			walkStruct(path+".", ft, onPath, fn)
+		case ft.Kind() == reflect.Map:
+			fn(path, []string{"object"})
+		case ft.Kind() == reflect.String:
+			fn(path, []string{"text", "keyword", "string", "date", "ip"})
+		case ft.Kind() == reflect.Bool:
+			fn(path, []string{"boolean"})
+		case ft.Kind() >= reflect.Int && ft.Kind() <= reflect.Uint64:
+			fn(path, []string{"long", "integer", "short", "byte", "unsigned_long"})
+		case ft.Kind() == reflect.Float32 || ft.Kind() == reflect.Float64:
+			fn(path, []string{"double", "float", "half_float", "scaled_float"})
+		case ft.Kind() == reflect.Slice || ft.Kind() == reflect.Array:
+			fn(path, []string{"binary"})
+		}
+	}
+}
+
+// mappingKeys are the keys allowed at the top level of a mapping.
+var mappingKeys = map[string]bool{
+	"properties":           true,
+	"dynamic":              true,
+	"dynamic_templates":    true,
+	"dynamic_date_formats": true,
+	"date_detection":       true,
+	"numeric_detection":    true,
+	"runtime":              true,
+	"enabled":              true,
+	"include_in_all":       true,
+	"_source":              true,
+	"_all":                 true,
+	"_routing":             true,
+	"_meta":                true,
+	"_parent":              true,
+	"_field_names":         true,
+	"_size":                true,
+}
+
+// ValidateMappings checks the mappings added with AddMapping without contacting elasticsearch:
+// every mapping must be a json object with known top-level keys and every field must have a
+// type or only the parameters of objects (properties, dynamic, enabled). Call it at startup to catch configuration errors before CheckStructure.
+func (s *Index) ValidateMappings() error {
+	for docType, mapping := range s.mappings {
+		var m map[string]interface{}
+		if err := json.Unmarshal([]byte(mapping), &m); err != nil {
+			return fmt.Errorf("mapping %s is not a valid json object: %v", docType, err)
+		}
+
+		for key := range m {
+			if !mappingKeys[key] {
+				return fmt.Errorf("mapping %s: unknown top-level key %q", docType, key)
+			}
+		}
+		if err := validateProperties("", m["properties"]); err != nil {
+			return fmt.Errorf("mapping %s: %v", docType, err)
+		}
+	}
+	return nil
+}
+
+// objectKeys are the parameters of fields mapped as object without an explicit type.
+var objectKeys = map[string]bool{
+	"properties":     true,
+	"dynamic":        true,
+	"enabled":        true,
+	"include_in_all": true,
+}
+
+func validateProperties(prefix string, properties interface{}) error {
+	if properties == nil {
+		return nil
+	}
+	props, ok := properties.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("properties of %q must be an object", strings.TrimSuffix(prefix, "."))
+	}
+
+	for name, p := range props {
+		path := prefix + name
+		field, ok := p.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("field %s must be an object", path)
+		}
+
+		typ, hasType := field["type"]
+		if hasType {
+			if _, ok := typ.(string); !ok {
+				return fmt.Errorf("type of field %s must be a string", path)
+			}
+		}
+		if !hasType {
+			// fields without type are objects, e.g. {"enabled": false} or {"dynamic": "strict"}
+			for key := range field {
+				if !objectKeys[key] {
+					return fmt.Errorf("field %s has no type and %q is not a parameter of objects", path, key)
+				}
+			}
+		}
+		if err := validateProperties(path+".", field["properties"]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
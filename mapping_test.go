@@ -0,0 +1,184 @@
+package eso
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var mappingConflictsTests = []struct {
+	doc       string
+	conflicts int
+}{
+	{`{"name": "bob", "age": 42, "address": {"zip": "8000", "city": "Zurich"}}`, 0},
+	{`{"name": "bob", "age": "42", "unknown": {"any": true}}`, 0},
+	{`{"name": "bob", "age": "forty-two"}`, 1},
+	{`{"name": {"first": "bob"}, "age": 42}`, 1},
+	{`{"address": "Zurich", "active": 1}`, 2},
+	{`{"tags": [{"zip": true}, {"zip": "8000"}]}`, 0},
+	{`{"address": {"zip": {"code": 8000}}}`, 1},
+}
+
+func TestMappingConflicts(t *testing.T) {
+	types := map[string]string{
+		"name":         "text",
+		"age":          "integer",
+		"active":       "boolean",
+		"address":      "object",
+		"address.zip":  "keyword",
+		"address.city": "text",
+	}
+	for _, tt := range mappingConflictsTests {
+		conflicts, err := mappingConflicts(types, tt.doc)
+		if err != nil {
+			t.Error(err)
+		} else if len(conflicts) != tt.conflicts {
+			t.Error(tt.doc, conflicts)
+		}
+	}
+}
+
+type validateAddress struct {
+	Zip  string `json:"zip"`
+	City string `json:"city"`
+}
+
+type validateBase struct {
+	ID int64 `json:"id"`
+}
+
+type validateDoc struct {
+	validateBase
+	Name     string           `json:"name,omitempty"`
+	Age      int              `json:"age"`
+	Active   string           `json:"active"`
+	Created  time.Time        `json:"created"`
+	Address  *validateAddress `json:"address"`
+	Tags     []string         `json:"tags"`
+	Extra    json.RawMessage  `json:"extra"`
+	Ignored  string           `json:"-"`
+	internal string
+	Scores   map[string]float64 `json:"scores"`
+}
+
+func TestStructMismatches(t *testing.T) {
+	types := map[string]string{
+		"id":           "long",
+		"name":         "text",
+		"age":          "integer",
+		"active":       "boolean",
+		"created":      "date",
+		"address":      "object",
+		"address.zip":  "keyword",
+		"address.city": "long",
+		"tags":         "keyword",
+	}
+	expected := []string{"active", "address.city", "scores"}
+
+	mismatches := structMismatches(reflect.TypeOf(validateDoc{}), types)
+	if len(mismatches) != len(expected) {
+		t.Fatal(mismatches)
+	}
+	for i, m := range mismatches {
+		if m.Field != expected[i] {
+			t.Error(m, expected[i])
+		}
+	}
+}
+
+type category struct {
+	Name     string      `json:"name"`
+	Parent   *category   `json:"parent"`
+	Children []*category `json:"children"`
+	Meta     struct {
+		Owner *category `json:"owner"`
+	} `json:"meta"`
+}
+
+func TestStructMismatchesRecursive(t *testing.T) {
+	types := map[string]string{
+		"name":     "keyword",
+		"parent":   "object",
+		"children": "nested",
+		"meta":     "object",
+	}
+	expected := []string{"meta.owner"}
+
+	mismatches := structMismatches(reflect.TypeOf(category{}), types)
+	if len(mismatches) != len(expected) {
+		t.Fatal(mismatches)
+	}
+	for i, m := range mismatches {
+		if m.Field != expected[i] {
+			t.Error(m, expected[i])
+		}
+	}
+}
+
+var structTypeTests = []struct {
+	v     interface{}
+	valid bool
+}{
+	{validateDoc{}, true},
+	{&validateDoc{}, true},
+	{(*validateDoc)(nil), true},
+	{nil, false},
+	{"doc", false},
+	{map[string]interface{}{}, false},
+	{new(int), false},
+}
+
+func TestStructType(t *testing.T) {
+	for _, tt := range structTypeTests {
+		if _, err := structType(tt.v); (err == nil) != tt.valid {
+			t.Errorf("%T: %v, expected valid %v", tt.v, err, tt.valid)
+		}
+	}
+}
+
+var validateMappingsTests = []struct {
+	mapping string
+	valid   bool
+}{
+	{`{"properties": {"id": {"type": "long"}, "user": {"properties": {"name": {"type": "text"}}}}}`, true},
+	{`{"dynamic": "strict", "_source": {"enabled": false}, "properties": {"id": {"type": "keyword"}}}`, true},
+	{`{"properties": {"id": {"type": "long"},}}`, false},
+	{`{"propertys": {"id": {"type": "long"}}}`, false},
+	{`{"properties": {"id": "long"}}`, false},
+	{`{"properties": {"user": {"properties": {"name": {"index": false}}}}}`, false},
+	{`{"properties": {"id": {"type": 1}}}`, false},
+	{`["properties"]`, false},
+	{`{"properties": {"meta": {"enabled": false}}}`, true},
+	{`{"properties": {"user": {"dynamic": "strict"}}}`, true},
+	{`{"properties": {"user": {"dynamic": "strict", "properties": {"name": {"type": "text"}}}}}`, true},
+	{`{"properties": {"user": {}}}`, true},
+}
+
+func TestValidateMappings(t *testing.T) {
+	for _, tt := range validateMappingsTests {
+		ind := &Index{mappings: map[string]string{"test": tt.mapping}}
+		if err := ind.ValidateMappings(); (err == nil) != tt.valid {
+			t.Error(tt.mapping, err)
+		}
+	}
+}
+
+func TestFormatMapOfStrings(t *testing.T) {
+	actual, err := formatMapOfStrings(map[string]string{
+		"index":   "{\n\t\"number_of_shards\": 5\n}",
+		"refresh": "5s",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"index":{"number_of_shards":5},"refresh":"5s"}`; actual != expected {
+		t.Error(actual, expected)
+	}
+
+	for _, invalid := range []string{`{"properties": {"id": {"type": "long"},}}`, ` ["a",]`} {
+		if _, err := formatMapOfStrings(map[string]string{"test": invalid}); err == nil {
+			t.Error("expected error for", invalid)
+		}
+	}
+}
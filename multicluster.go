@@ -0,0 +1,93 @@
+package eso
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+// PartialResultsError is returned along with the merged hits by MultiClusterSearch if some
+// but not all clusters failed. Failed holds the errors keyed by client name.
+type PartialResultsError struct {
+	Failed map[string]error
+}
+
+func (s *PartialResultsError) Error() string {
+	names := make([]string, 0, len(s.Failed))
+	for name := range s.Failed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	reasons := make([]string, 0, len(names))
+	for _, name := range names {
+		reasons = append(reasons, fmt.Sprintf("%s: %v", name, s.Failed[name]))
+	}
+	return fmt.Sprintf("partial results, %d clusters failed: %s", len(s.Failed), strings.Join(reasons, "; "))
+}
+
+// MultiClusterSearch searches index on the clusters of all given registered clients concurrently and
+// returns the top size hits of all clusters merged by score. The Index of every hit is the index of
+// its cluster. If some clusters fail, the hits of the others are returned with a *PartialResultsError
+// listing the failures; if all fail, only the error is returned.
+func MultiClusterSearch(ctx context.Context, clients []string, index string, query interface{}, size int) ([]*elastic.SearchHit, error) {
+	var (
+		m      sync.Mutex
+		wg     sync.WaitGroup
+		hits   []*elastic.SearchHit
+		failed = map[string]error{}
+	)
+	for _, name := range clients {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+
+			res, err := searchCluster(ctx, name, index, query, size)
+			m.Lock()
+			defer m.Unlock()
+			if err != nil {
+				failed[name] = err
+				return
+			}
+			if res.Hits != nil {
+				hits = append(hits, res.Hits.Hits...)
+			}
+		}(name)
+	}
+	wg.Wait()
+
+	if len(failed) != 0 && len(failed) == len(clients) {
+		return nil, &PartialResultsError{Failed: failed}
+	}
+
+	// hits without score (sorted searches) go last
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score == nil || hits[j].Score == nil {
+			return hits[j].Score == nil && hits[i].Score != nil
+		}
+		return *hits[i].Score > *hits[j].Score
+	})
+	if len(hits) > size {
+		hits = hits[:size]
+	}
+
+	if len(failed) != 0 {
+		return hits, &PartialResultsError{Failed: failed}
+	}
+	return hits, nil
+}
+
+func searchCluster(ctx context.Context, clientName, index string, query interface{}, size int) (*elastic.SearchResult, error) {
+	dt, err := DocTypeRef{Client: clientName, Index: index}.docType()
+	if err != nil {
+		return nil, err
+	}
+	return dt.search(ctx, []string{index}, query, []SearchOption{func(req *searchRequest) error {
+		req.body["size"] = size
+		return nil
+	}})
+}
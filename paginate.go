@@ -0,0 +1,168 @@
+package eso
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+// ErrInvalidCursor is returned when a cursor token was tampered with or belongs to another query.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// NewPaginator creates a Paginator returning size hits per page. The sorters must give the hits
+// a total order, so the last one should be on a unique field; without sorters Page fails.
+// key signs the cursor tokens.
+func NewPaginator(docType *DocType, query interface{}, size int, key []byte, sorters ...elastic.Sorter) *Paginator {
+	return &Paginator{
+		docType: docType,
+		query:   query,
+		size:    size,
+		key:     key,
+		sorters: sorters,
+	}
+}
+
+// Paginator pages through search results using search_after and hands out opaque cursor
+// tokens instead of the raw sort values.
+type Paginator struct {
+	docType *DocType
+	query   interface{}
+	size    int
+	key     []byte
+	sorters []elastic.Sorter
+}
+
+type cursorPayload struct {
+	Query     []byte            `json:"q"`
+	SortAfter []json.RawMessage `json:"s"`
+}
+
+// Page returns the page after cursor (empty for the first page) and the cursor of the next page.
+// The next cursor is empty once the last page was returned.
+func (s *Paginator) Page(ctx context.Context, cursor string) ([]*elastic.SearchHit, string, error) {
+	if len(s.sorters) == 0 {
+		return nil, "", errors.New("paginator needs at least one sorter")
+	}
+
+	fingerprint, err := s.fingerprint()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var after []json.RawMessage
+	if cursor != "" {
+		if after, err = s.decodeCursor(cursor, fingerprint); err != nil {
+			return nil, "", err
+		}
+	}
+
+	var sorts [][]json.RawMessage
+	opts := []SearchOption{
+		SortBy(s.sorters...),
+		func(req *searchRequest) error {
+			req.body["size"] = s.size
+			if after != nil {
+				req.body["search_after"] = after
+			}
+			req.decoders = append(req.decoders, func(res json.RawMessage) error {
+				// read the sort values raw so large numbers are not rounded
+				var r struct {
+					Hits struct {
+						Hits []struct {
+							Sort []json.RawMessage `json:"sort"`
+						} `json:"hits"`
+					} `json:"hits"`
+				}
+				if err := json.Unmarshal(res, &r); err != nil {
+					return err
+				}
+				for _, hit := range r.Hits.Hits {
+					sorts = append(sorts, hit.Sort)
+				}
+				return nil
+			})
+			return nil
+		},
+	}
+
+	res, err := s.docType.search(ctx, []string{s.docType.readIndex()}, s.query, opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	hits := res.Hits.Hits
+	if len(hits) < s.size || len(sorts) == 0 {
+		return hits, "", nil
+	}
+
+	next, err := s.encodeCursor(fingerprint, sorts[len(sorts)-1])
+	if err != nil {
+		return nil, "", err
+	}
+	return hits, next, nil
+}
+
+// fingerprint identifies query and sort so cursors can't be used with another search.
+func (s *Paginator) fingerprint() ([]byte, error) {
+	body, err := searchBody(s.query)
+	if err != nil {
+		return nil, err
+	}
+	req := &searchRequest{body: body}
+	if err := SortBy(s.sorters...)(req); err != nil {
+		return nil, err
+	}
+
+	d, err := json.Marshal(req.body)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(d)
+	return sum[:8], nil
+}
+
+func (s *Paginator) encodeCursor(fingerprint []byte, sortAfter []json.RawMessage) (string, error) {
+	payload, err := json.Marshal(cursorPayload{Query: fingerprint, SortAfter: sortAfter})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(s.sign(payload)), nil
+}
+
+func (s *Paginator) decodeCursor(cursor string, fingerprint []byte) ([]json.RawMessage, error) {
+	parts := strings.Split(cursor, ".")
+	if len(parts) != 2 {
+		return nil, ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(sig, s.sign(payload)) {
+		return nil, ErrInvalidCursor
+	}
+
+	var p cursorPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if !bytes.Equal(p.Query, fingerprint) || len(p.SortAfter) == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return p.SortAfter, nil
+}
+
+func (s *Paginator) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
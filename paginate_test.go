@@ -0,0 +1,98 @@
+package eso
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+var cursorTests = []struct {
+	tamper   func(cursor string) string
+	expected error
+}{
+	{func(c string) string { return c }, nil},
+	{func(c string) string { return "x" + c }, ErrInvalidCursor},
+	{func(c string) string { return c[:strings.Index(c, ".")] }, ErrInvalidCursor},
+	{func(c string) string { return c + "x" }, ErrInvalidCursor},
+	{func(c string) string { return "" + "." + c[strings.Index(c, ".")+1:] }, ErrInvalidCursor},
+}
+
+func TestCursor(t *testing.T) {
+	p := &Paginator{key: []byte("secret")}
+	fingerprint := []byte("query1")
+	sortAfter := []json.RawMessage{json.RawMessage(`9007199254740993`), json.RawMessage(`"id1"`)}
+
+	cursor, err := p.encodeCursor(fingerprint, sortAfter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range cursorTests {
+		actual, err := p.decodeCursor(tt.tamper(cursor), fingerprint)
+		if err != tt.expected {
+			t.Error(err, tt.expected)
+		} else if err == nil && (string(actual[0]) != "9007199254740993" || string(actual[1]) != `"id1"`) {
+			t.Error(actual, sortAfter)
+		}
+	}
+
+	if _, err := p.decodeCursor(cursor, []byte("query2")); err != ErrInvalidCursor {
+		t.Error(err, ErrInvalidCursor)
+	}
+	if _, err := (&Paginator{key: []byte("other")}).decodeCursor(cursor, fingerprint); err != ErrInvalidCursor {
+		t.Error(err, ErrInvalidCursor)
+	}
+}
+
+var pageIndexTests = []struct {
+	docType  func(idx *Index) *DocType
+	expected string
+}{
+	{func(idx *Index) *DocType { return NewDocType(idx, "doc") }, "/unit_page/_search"},
+	{func(idx *Index) *DocType { return NewDocTypeAliases(idx, "doc", "unit_page_write", "unit_page_read") }, "/unit_page_read/_search"},
+}
+
+func TestPageIndex(t *testing.T) {
+	var (
+		m    sync.Mutex
+		path string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/_search") {
+			m.Lock()
+			path = r.URL.Path
+			m.Unlock()
+		}
+		w.Write([]byte(`{"hits": {"total": 0, "hits": []}}`))
+	}))
+	defer srv.Close()
+
+	RegisterClient("page_test", srv.URL)
+	idx := NewIndex("unit_page", "page_test")
+
+	for _, tt := range pageIndexTests {
+		p := NewPaginator(tt.docType(idx), nil, 10, []byte("secret"), elastic.NewFieldSort("id"))
+		if _, _, err := p.Page(context.Background(), ""); err != nil {
+			t.Fatal(err)
+		}
+
+		m.Lock()
+		if path != tt.expected {
+			t.Error(path, tt.expected)
+		}
+		m.Unlock()
+	}
+}
+
+func TestPageWithoutSorters(t *testing.T) {
+	p := NewPaginator(&DocType{}, nil, 10, []byte("secret"))
+	if _, _, err := p.Page(context.Background(), ""); err == nil {
+		t.Error("expected error for paginator without sorters")
+	}
+}
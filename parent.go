@@ -0,0 +1,37 @@
+package eso
+
+import (
+	"context"
+	"io"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+// OrphanedIDs returns the ids of the documents of the child doc type whose parent document of type
+// parentType does not exist (anymore), e.g. to clean them up after parents were deleted.
+// The child type must be mapped with _parent pointing to parentType.
+func (s *DocType) OrphanedIDs(ctx context.Context, parentType string) ([]string, error) {
+	query := elastic.NewBoolQuery().MustNot(elastic.NewHasParentQuery(parentType, elastic.NewMatchAllQuery()))
+	body, err := searchBody(query)
+	if err != nil {
+		return nil, err
+	}
+	body["_source"] = false
+
+	cursor := s.scroll(body)
+	defer cursor.Close(context.Background())
+
+	var ids []string
+	for {
+		res, err := cursor.Next(ctx)
+		if err == io.EOF {
+			return ids, nil
+		}
+		if err != nil {
+			return ids, err
+		}
+		for _, hit := range res.Hits.Hits {
+			ids = append(ids, hit.Id)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package eso
+
+import (
+	"context"
+	"sort"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+// ScoreBuilder composes function_score queries from field value factors and decay functions.
+// The result of Query can be passed to Search.
+type ScoreBuilder struct {
+	q    *elastic.FunctionScoreQuery
+	last func(weight float64)
+}
+
+// NewScoreBuilder creates a function_score builder scoring the documents matched by query.
+// A nil query scores all documents.
+func NewScoreBuilder(query elastic.Query) *ScoreBuilder {
+	q := elastic.NewFunctionScoreQuery()
+	if query != nil {
+		q = q.Query(query)
+	}
+	return &ScoreBuilder{q: q, last: func(float64) {}}
+}
+
+// FieldValueFactor adds a field_value_factor function. Modifier can be empty or one of
+// log, log1p, log2p, ln, ln1p, ln2p, square, sqrt, reciprocal.
+func (s *ScoreBuilder) FieldValueFactor(field string, factor float64, modifier string) *ScoreBuilder {
+	f := elastic.NewFieldValueFactorFunction().Field(field).Factor(factor)
+	if modifier != "" {
+		f = f.Modifier(modifier)
+	}
+	s.q = s.q.AddScoreFunc(f)
+	s.last = func(w float64) { f.Weight(w) }
+	return s
+}
+
+// Gauss adds a gauss decay function on field.
+func (s *ScoreBuilder) Gauss(field string, origin, scale interface{}, decay float64) *ScoreBuilder {
+	f := elastic.NewGaussDecayFunction().FieldName(field).Origin(origin).Scale(scale).Decay(decay)
+	s.q = s.q.AddScoreFunc(f)
+	s.last = func(w float64) { f.Weight(w) }
+	return s
+}
+
+// Linear adds a linear decay function on field.
+func (s *ScoreBuilder) Linear(field string, origin, scale interface{}, decay float64) *ScoreBuilder {
+	f := elastic.NewLinearDecayFunction().FieldName(field).Origin(origin).Scale(scale).Decay(decay)
+	s.q = s.q.AddScoreFunc(f)
+	s.last = func(w float64) { f.Weight(w) }
+	return s
+}
+
+// Exp adds an exponential decay function on field.
+func (s *ScoreBuilder) Exp(field string, origin, scale interface{}, decay float64) *ScoreBuilder {
+	f := elastic.NewExponentialDecayFunction().FieldName(field).Origin(origin).Scale(scale).Decay(decay)
+	s.q = s.q.AddScoreFunc(f)
+	s.last = func(w float64) { f.Weight(w) }
+	return s
+}
+
+// Weight sets the weight of the function added last.
+func (s *ScoreBuilder) Weight(weight float64) *ScoreBuilder {
+	s.last(weight)
+	return s
+}
+
+// BoostMode sets how the function score is combined with the query score
+// (multiply, replace, sum, avg, max, min).
+func (s *ScoreBuilder) BoostMode(mode string) *ScoreBuilder {
+	s.q = s.q.BoostMode(mode)
+	return s
+}
+
+// ScoreMode sets how the function scores are combined
+// (multiply, sum, avg, first, max, min).
+func (s *ScoreBuilder) ScoreMode(mode string) *ScoreBuilder {
+	s.q = s.q.ScoreMode(mode)
+	return s
+}
+
+// Query returns the composed function_score query.
+func (s *ScoreBuilder) Query() elastic.Query {
+	return s.q
+}
+
+// RecencyBoost multiplies the scores of the documents matched by query with a gauss decay on
+// the date field: documents at origin (empty for "now") keep their score, documents scale
+// (e.g. "7d") away from it get decay (e.g. 0.5) of it. Use NewScoreBuilder(query).Exp(...)
+// for scores dropping sharply right after origin.
+func RecencyBoost(query elastic.Query, field, origin, scale string, decay float64) elastic.Query {
+	if origin == "" {
+		origin = "now"
+	}
+	return NewScoreBuilder(query).Gauss(field, origin, scale, decay).BoostMode("multiply").Query()
+}
+
+// Named tags a query with a name. The names of the named queries a hit matched are
+// listed in its matched_queries (see Matched). Works for any query by wrapping it in a bool query.
+func Named(name string, query elastic.Query) elastic.Query {
+	return elastic.NewBoolQuery().Must(query).QueryName(name)
+}
+
+// Matched reports if hit matched the query tagged with name.
+func Matched(hit *elastic.SearchHit, name string) bool {
+	for _, n := range hit.MatchedQueries {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Nested queries the nested objects at path. A non empty innerHits names the inner hits which
+// return the matching nested objects with every hit (see NestedHits).
+func Nested(path string, query elastic.Query, innerHits string) elastic.Query {
+	q := elastic.NewNestedQuery(path, query)
+	if innerHits != "" {
+		q = q.InnerHit(elastic.NewInnerHit().Name(innerHits))
+	}
+	return q
+}
+
+// NestedHits returns the nested objects of hit which matched the Nested query with the given inner hits name.
+// The offset of the object in its array is in the Nested field of each returned hit.
+func NestedHits(hit *elastic.SearchHit, innerHits string) []*elastic.SearchHit {
+	inner, ok := hit.InnerHits[innerHits]
+	if !ok || inner == nil || inner.Hits == nil {
+		return nil
+	}
+	return inner.Hits.Hits
+}
+
+// MultiMatchOptions configures DocType.MultiMatch. All fields are optional.
+type MultiMatchOptions struct {
+	// Type is best_fields (default), most_fields, cross_fields, phrase or phrase_prefix.
+	Type string
+	// Fuzziness is e.g. "AUTO" or "1". Not supported by cross_fields and the phrase types.
+	Fuzziness string
+	// Operator is "or" (default) or "and".
+	Operator string
+	// Size is the number of hits to return; 0 uses the elasticsearch default of 10.
+	Size int
+}
+
+// MultiMatch searches text in several fields. fields maps the field names to their boost;
+// a boost <= 0 leaves the field unboosted.
+func (s *DocType) MultiMatch(ctx context.Context, text string, fields map[string]float64, opts MultiMatchOptions) (*elastic.SearchResult, error) {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	q := elastic.NewMultiMatchQuery(text)
+	for _, name := range names {
+		if boost := fields[name]; boost > 0 {
+			q = q.FieldWithBoost(name, boost)
+		} else {
+			q = q.Field(name)
+		}
+	}
+	if opts.Type != "" {
+		q = q.Type(opts.Type)
+	}
+	if opts.Fuzziness != "" {
+		q = q.Fuzziness(opts.Fuzziness)
+	}
+	if opts.Operator != "" {
+		q = q.Operator(opts.Operator)
+	}
+
+	var searchOpts []SearchOption
+	if opts.Size > 0 {
+		searchOpts = append(searchOpts, func(req *searchRequest) error {
+			req.body["size"] = opts.Size
+			return nil
+		})
+	}
+	return s.search(ctx, []string{s.readIndex()}, q, searchOpts)
+}
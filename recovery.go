@@ -0,0 +1,56 @@
+package eso
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+)
+
+// IndexRecovery holds the recovery state of the shards of an index.
+type IndexRecovery struct {
+	Shards []*ShardRecovery `json:"shards"`
+}
+
+// ShardRecovery is the recovery state of a single shard copy.
+type ShardRecovery struct {
+	ID      int  `json:"id"`
+	Primary bool `json:"primary"`
+	// Type is e.g. "peer", "store" or "snapshot".
+	Type string `json:"type"`
+	// Stage is one of init, index, verify_index, translog, finalize and done.
+	Stage      string       `json:"stage"`
+	SourceNode RecoveryNode `json:"source"`
+	TargetNode RecoveryNode `json:"target"`
+	Index      struct {
+		Size struct {
+			// Percent of the bytes recovered, e.g. "42.0%".
+			Percent string `json:"percent"`
+		} `json:"size"`
+		Files struct {
+			Percent string `json:"percent"`
+		} `json:"files"`
+	} `json:"index"`
+}
+
+// RecoveryNode is the source or target node of a shard recovery.
+type RecoveryNode struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Host string `json:"host"`
+}
+
+// Recovery returns the recovery state of the shards of the index (or of all indices behind
+// an alias or pattern) keyed by index name.
+func (s *Index) Recovery(ctx context.Context) (map[string]*IndexRecovery, error) {
+	params := url.Values{"human": []string{"true"}}
+	res, err := s.cl.perform(ctx, "GET", "/"+s.name+"/_recovery", params, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := map[string]*IndexRecovery{}
+	if err := json.Unmarshal(res.Body, &ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
@@ -0,0 +1,178 @@
+package eso
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+// ReindexOption configures a reindex started with Index.Reindex.
+type ReindexOption func(body map[string]interface{}, params url.Values)
+
+// RequestsPerSecond throttles a reindex to the given number of documents per second.
+// A value <= 0 disables throttling.
+func RequestsPerSecond(rps float64) ReindexOption {
+	return func(body map[string]interface{}, params url.Values) {
+		params.Set("requests_per_second", formatRequestsPerSecond(rps))
+	}
+}
+
+func formatRequestsPerSecond(rps float64) string {
+	if rps <= 0 {
+		return "-1"
+	}
+	return strconv.FormatFloat(rps, 'f', -1, 64)
+}
+
+// Reindex starts copying the documents matching query (nil for all) from this index into dest.
+// The reindex runs in the background; the returned task id can be passed to Rethrottle.
+func (s *Index) Reindex(ctx context.Context, dest string, query interface{}, opts ...ReindexOption) (string, error) {
+	source := map[string]interface{}{"index": s.name}
+	if query != nil {
+		src, err := querySource(query)
+		if err != nil {
+			return "", err
+		}
+		source["query"] = src
+	}
+
+	body := map[string]interface{}{
+		"source": source,
+		"dest":   map[string]interface{}{"index": dest},
+	}
+	params := url.Values{"wait_for_completion": []string{"false"}}
+	for _, opt := range opts {
+		opt(body, params)
+	}
+
+	res, err := s.cl.perform(ctx, "POST", "/_reindex", params, body)
+	if err != nil {
+		return "", err
+	}
+
+	var task struct {
+		Task string `json:"task"`
+	}
+	if err := json.Unmarshal(res.Body, &task); err != nil {
+		return "", err
+	}
+	return task.Task, nil
+}
+
+// Rethrottle changes the requests per second of a running reindex task.
+// A value <= 0 disables throttling.
+func (s *Index) Rethrottle(ctx context.Context, taskID string, rps float64) error {
+	params := url.Values{"requests_per_second": []string{formatRequestsPerSecond(rps)}}
+	_, err := s.cl.perform(ctx, "POST", "/_reindex/"+url.PathEscape(taskID)+"/_rethrottle", params, nil)
+	return err
+}
+
+// DocTypeRef references a doc type on a registered client. An empty Type refers to a typeless index.
+type DocTypeRef struct {
+	Client string
+	Index  string
+	Type   string
+}
+
+func (s DocTypeRef) docType() (*DocType, error) {
+	cl, err := getClient(s.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := &Index{
+		cl:       cl,
+		name:     s.Index,
+		settings: map[string]string{},
+		mappings: map[string]string{},
+	}
+	if s.Type == "" {
+		return NewTypelessDocType(idx, ""), nil
+	}
+	return NewDocType(idx, s.Type), nil
+}
+
+// CrossClusterCopy copies the documents matching query (nil for all) from src to dst, which may be
+// on different clusters, by scrolling the source and bulk indexing into the destination.
+// Ids are kept. Returns the number of documents copied; failed documents are returned as *BulkError.
+func CrossClusterCopy(ctx context.Context, src, dst DocTypeRef, query interface{}) (int64, error) {
+	return copyDocs(ctx, src, dst, query, nil)
+}
+
+// ReindexWithIDs copies the documents matching query (nil for all) from src to dst like CrossClusterCopy,
+// writing every document with the id idFn returns for its source and old id, e.g. to migrate from
+// sequential ids to content hashes. An idFn error stops the copy. Server side reindex cannot change ids.
+func ReindexWithIDs(ctx context.Context, src, dst DocTypeRef, query interface{}, idFn func(src json.RawMessage, oldID string) (newID string, err error)) (int64, error) {
+	if idFn == nil {
+		return 0, errors.New("no id function")
+	}
+	return copyDocs(ctx, src, dst, query, idFn)
+}
+
+// copyDocs scrolls src and bulk indexes the documents into dst. A nil idFn keeps the ids.
+func copyDocs(ctx context.Context, src, dst DocTypeRef, query interface{}, idFn func(src json.RawMessage, oldID string) (string, error)) (int64, error) {
+	srcType, err := src.docType()
+	if err != nil {
+		return 0, err
+	}
+	dstType, err := dst.docType()
+	if err != nil {
+		return 0, err
+	}
+
+	cursor, err := srcType.Scroll(query)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(context.Background())
+
+	var (
+		copied int64
+		failed []BulkItemError
+	)
+	for {
+		res, err := cursor.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return copied, err
+		}
+
+		reqs := make([]elastic.BulkableRequest, 0, len(res.Hits.Hits))
+		for _, hit := range res.Hits.Hits {
+			if hit.Source == nil {
+				continue
+			}
+			id := hit.Id
+			if idFn != nil {
+				if id, err = idFn(*hit.Source, hit.Id); err != nil {
+					return copied, fmt.Errorf("id of document %s: %w", hit.Id, err)
+				}
+			}
+			reqs = append(reqs, dstType.bulkIndexRequest(*hit.Source, id, nil))
+		}
+		if len(reqs) == 0 {
+			continue
+		}
+
+		items, _, err := dstType.doBulk(ctx, reqs, nil, DefaultBulkRetries)
+		if err != nil {
+			return copied, err
+		}
+		itemErrs := bulkItemErrors(items)
+		copied += int64(len(items) - len(itemErrs))
+		failed = append(failed, itemErrs...)
+	}
+
+	if len(failed) != 0 {
+		return copied, &BulkError{Items: failed}
+	}
+	return copied, nil
+}
@@ -29,7 +29,7 @@ func Init() {
 				},
 				"name": {
 					"type": "text"
-				},
+				}
 			}
 		}`)
 
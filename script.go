@@ -0,0 +1,84 @@
+package eso
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+)
+
+// NewInlineScript creates a painless script sent with the request.
+func NewInlineScript(source string, params map[string]interface{}) *Script {
+	return &Script{source: source, params: params}
+}
+
+// NewStoredScript references a script stored with PutStoredScript by id.
+func NewStoredScript(id string, params map[string]interface{}) *Script {
+	return &Script{id: id, params: params}
+}
+
+// Script is an inline or stored script for Update and UpdateByQuery.
+type Script struct {
+	id     string
+	source string
+	params map[string]interface{}
+}
+
+// MarshalJSON encodes the script as a stored script reference (id) or an inline painless source with its params.
+func (s *Script) MarshalJSON() ([]byte, error) {
+	script := map[string]interface{}{}
+	if s.id != "" {
+		script["id"] = s.id
+	} else {
+		script["lang"] = "painless"
+		script["source"] = s.source
+	}
+	if len(s.params) != 0 {
+		script["params"] = s.params
+	}
+	return json.Marshal(script)
+}
+
+// PutStoredScript stores a painless script under id on the cluster behind a registered client.
+// Reference it with NewStoredScript.
+func PutStoredScript(ctx context.Context, clientName, id, script string) error {
+	cl, err := getClient(clientName)
+	if err != nil {
+		return err
+	}
+
+	body := map[string]interface{}{
+		"script": map[string]interface{}{"lang": "painless", "source": script},
+	}
+	res, err := cl.perform(ctx, "PUT", "/_scripts/"+url.PathEscape(id), nil, body)
+	if err != nil {
+		return err
+	}
+
+	return checkAck(res.Body, "stored script")
+}
+
+// UpdateByQuery runs script on all documents matching query (nil for all) and returns the
+// number of updated documents. Version conflicts abort the update.
+func (s *DocType) UpdateByQuery(ctx context.Context, query interface{}, script *Script) (int64, error) {
+	body := map[string]interface{}{"script": script}
+	if query != nil {
+		src, err := querySource(query)
+		if err != nil {
+			return 0, err
+		}
+		body["query"] = src
+	}
+
+	res, err := s.cl.perform(ctx, "POST", s.byQueryPath("_update_by_query"), nil, body)
+	if err != nil {
+		return 0, err
+	}
+
+	var ret struct {
+		Updated int64 `json:"updated"`
+	}
+	if err := json.Unmarshal(res.Body, &ret); err != nil {
+		return 0, err
+	}
+	return ret.Updated, nil
+}
@@ -0,0 +1,156 @@
+package eso
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+const scrollSize = 500
+
+// DefaultScrollKeepAlive is how long elasticsearch keeps a scroll context open between two batches.
+var DefaultScrollKeepAlive = "1m"
+
+// ScrollCursor iterates over all documents matching a query using the scroll api.
+type ScrollCursor struct {
+	svc *elastic.ScrollService
+}
+
+// Scroll returns a cursor over all documents matching query. Close the cursor when done
+// to free the scroll context on the server.
+func (s *DocType) Scroll(query interface{}) (*ScrollCursor, error) {
+	body, err := searchBody(query)
+	if err != nil {
+		return nil, err
+	}
+	return s.scroll(body), nil
+}
+
+// SlicedScroll splits a scroll over all documents matching query into numSlices independent
+// cursors which can be consumed concurrently.
+func (s *DocType) SlicedScroll(ctx context.Context, query interface{}, numSlices int) ([]*ScrollCursor, error) {
+	if numSlices < 1 {
+		return nil, fmt.Errorf("invalid number of slices %d", numSlices)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if numSlices == 1 {
+		cursor, err := s.Scroll(query)
+		if err != nil {
+			return nil, err
+		}
+		return []*ScrollCursor{cursor}, nil
+	}
+
+	cursors := make([]*ScrollCursor, 0, numSlices)
+	for i := 0; i < numSlices; i++ {
+		body, err := searchBody(query)
+		if err != nil {
+			return nil, err
+		}
+		body["slice"] = map[string]int{"id": i, "max": numSlices}
+		cursors = append(cursors, s.scroll(body))
+	}
+	return cursors, nil
+}
+
+func (s *DocType) scroll(body map[string]interface{}) *ScrollCursor {
+	svc := s.cl.conn.Scroll(s.readIndex()).Body(body).Size(scrollSize).KeepAlive(DefaultScrollKeepAlive)
+	if !s.typeless {
+		svc = svc.Type(s.name)
+	}
+	return &ScrollCursor{svc: svc}
+}
+
+// Size sets the number of documents fetched per batch.
+func (s *ScrollCursor) Size(size int) *ScrollCursor {
+	s.svc = s.svc.Size(size)
+	return s
+}
+
+// KeepAlive sets how long the scroll context stays open between two batches (e.g. "5m").
+// Raise it if processing a batch takes longer than DefaultScrollKeepAlive.
+func (s *ScrollCursor) KeepAlive(keepAlive string) *ScrollCursor {
+	s.svc = s.svc.KeepAlive(keepAlive)
+	return s
+}
+
+// Next fetches the next batch of documents. It returns io.EOF once all documents are read.
+// If ctx is cancelled the scroll context on the server is cleared. If the scroll context
+// expired because a batch took longer than the keep alive, the error matches ErrScrollExpired.
+func (s *ScrollCursor) Next(ctx context.Context) (*elastic.SearchResult, error) {
+	if err := ctx.Err(); err != nil {
+		s.Close(context.Background())
+		return nil, err
+	}
+
+	res, err := s.svc.Do(ctx)
+	err = rateLimited(err)
+	if err != nil && ctx.Err() != nil {
+		s.Close(context.Background())
+		return nil, ctx.Err()
+	}
+	if scrollExpired(err) {
+		return nil, &scrollExpiredError{err: err}
+	}
+	return res, err
+}
+
+// Close clears the scroll context on the server.
+func (s *ScrollCursor) Close(ctx context.Context) error {
+	return s.svc.Clear(ctx)
+}
+
+// ExportNDJSON scrolls over all documents matching query and writes their sources as
+// newline delimited json to w. Output is flushed after every batch. Returns the number
+// of documents written.
+func (s *DocType) ExportNDJSON(ctx context.Context, query interface{}, w io.Writer) (int64, error) {
+	cursor, err := s.Scroll(query)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(context.Background())
+
+	var (
+		count int64
+		buf   = bufio.NewWriter(w)
+	)
+	for {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+
+		res, err := cursor.Next(ctx)
+		if err == io.EOF {
+			return count, buf.Flush()
+		}
+		if err != nil {
+			return count, err
+		}
+
+		for _, hit := range res.Hits.Hits {
+			if hit.Source == nil {
+				continue
+			}
+			if _, err := buf.Write(*hit.Source); err != nil {
+				return count, err
+			}
+			if err := buf.WriteByte('\n'); err != nil {
+				return count, err
+			}
+			count++
+		}
+
+		if err := buf.Flush(); err != nil {
+			return count, err
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+}
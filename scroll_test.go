@@ -0,0 +1,43 @@
+package eso
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestScrollCancelClearsScroll(t *testing.T) {
+	var cleared int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" && r.URL.Path == "/_search/scroll" {
+			atomic.AddInt32(&cleared, 1)
+			w.Write([]byte(`{"succeeded": true, "num_freed": 1}`))
+			return
+		}
+		w.Write([]byte(`{"_scroll_id": "scroll1", "hits": {"total": 3, "hits": [{"_id": "1", "_source": {"test": "bla"}}]}}`))
+	}))
+	defer srv.Close()
+
+	RegisterClient("scroll_test", srv.URL)
+	doc := NewDocType(NewIndex("unit_test", "scroll_test"), "test")
+
+	cursor, err := doc.Scroll(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := cursor.Next(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	if _, err := cursor.Next(ctx); err != context.Canceled {
+		t.Error(err, context.Canceled)
+	}
+	if atomic.LoadInt32(&cleared) != 1 {
+		t.Error("scroll was not cleared after cancel")
+	}
+}
@@ -0,0 +1,507 @@
+package eso
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+// SearchOption modifies a search request before it is sent.
+type SearchOption func(req *searchRequest) error
+
+type searchRequest struct {
+	body   map[string]interface{}
+	params url.Values
+	// decoders read additional data from the raw response not covered by elastic.SearchResult
+	decoders []func(res json.RawMessage) error
+}
+
+// PostFilter filters the hits after the aggregations have been computed.
+// Aggregations see all documents matching the query, hits only the ones also matching filter.
+func PostFilter(filter interface{}) SearchOption {
+	return func(req *searchRequest) error {
+		src, err := querySource(filter)
+		if err != nil {
+			return err
+		}
+		req.body["post_filter"] = src
+		return nil
+	}
+}
+
+// TerminateAfter stops collecting hits after n documents per shard. terminatedEarly (may be nil)
+// is set to true if the search stopped early, meaning the total hits are a lower bound.
+func TerminateAfter(n int, terminatedEarly *bool) SearchOption {
+	return func(req *searchRequest) error {
+		req.body["terminate_after"] = n
+		if terminatedEarly == nil {
+			return nil
+		}
+
+		req.decoders = append(req.decoders, func(res json.RawMessage) error {
+			var r struct {
+				TerminatedEarly bool `json:"terminated_early"`
+			}
+			if err := json.Unmarshal(res, &r); err != nil {
+				return err
+			}
+			*terminatedEarly = r.TerminatedEarly
+			return nil
+		})
+		return nil
+	}
+}
+
+// HitSeqNo is the sequence number and primary term of a search hit.
+type HitSeqNo struct {
+	Index       string `json:"_index"`
+	ID          string `json:"_id"`
+	SeqNo       int64  `json:"_seq_no"`
+	PrimaryTerm int64  `json:"_primary_term"`
+}
+
+// SeqNoPrimaryTerm requests the sequence number and primary term of every hit (elasticsearch 6.7+).
+// seqNos is set to one entry per hit in the order of the hits. Sort by _seq_no to consume the
+// changes of a single shard in order.
+func SeqNoPrimaryTerm(seqNos *[]HitSeqNo) SearchOption {
+	return func(req *searchRequest) error {
+		req.body["seq_no_primary_term"] = true
+		req.decoders = append(req.decoders, func(res json.RawMessage) error {
+			var r struct {
+				Hits struct {
+					Hits []HitSeqNo `json:"hits"`
+				} `json:"hits"`
+			}
+			if err := json.Unmarshal(res, &r); err != nil {
+				return err
+			}
+			*seqNos = r.Hits.Hits
+			return nil
+		})
+		return nil
+	}
+}
+
+// SearchTimeout makes elasticsearch stop collecting hits after d and return the hits collected
+// so far, with TimedOut of the result set, instead of failing the search. It does not cancel
+// the http request; use a context deadline for that.
+func SearchTimeout(d time.Duration) SearchOption {
+	return func(req *searchRequest) error {
+		ms := d.Milliseconds()
+		if ms < 1 {
+			ms = 1
+		}
+		req.body["timeout"] = strconv.FormatInt(ms, 10) + "ms"
+		return nil
+	}
+}
+
+// TrackTotalHits makes elasticsearch 7+ count all hits exactly instead of stopping at 10000.
+func TrackTotalHits() SearchOption {
+	return trackTotalHits(true)
+}
+
+// TrackTotalHitsUpTo makes elasticsearch 7+ count hits exactly up to limit.
+func TrackTotalHitsUpTo(limit int) SearchOption {
+	return trackTotalHits(limit)
+}
+
+func trackTotalHits(track interface{}) SearchOption {
+	return func(req *searchRequest) error {
+		req.body["track_total_hits"] = track
+		// return the total as a number so it fits elastic.SearchHits.TotalHits
+		req.params.Set("rest_total_hits_as_int", "true")
+		return nil
+	}
+}
+
+// RequestCache enables or disables the shard request cache for the search. It is most useful
+// for repeated size 0 aggregation queries on indices which don't change often.
+func RequestCache(enabled bool) SearchOption {
+	return func(req *searchRequest) error {
+		req.params.Set("request_cache", strconv.FormatBool(enabled))
+		return nil
+	}
+}
+
+// Preference pins the search to shard copies, e.g. "_local" or a session id, so that
+// paginated requests of the same user are scored consistently.
+func Preference(preference string) SearchOption {
+	return func(req *searchRequest) error {
+		req.params.Set("preference", preference)
+		return nil
+	}
+}
+
+// MaxConcurrentShardRequests limits the number of shards a single search queries at once
+// per node, relieving the coordinating node on wide index patterns.
+func MaxConcurrentShardRequests(n int) SearchOption {
+	return func(req *searchRequest) error {
+		req.params.Set("max_concurrent_shard_requests", strconv.Itoa(n))
+		return nil
+	}
+}
+
+// IndicesBoost multiplies the scores of hits by the boost of the index they come from, e.g. to
+// prefer the current index over the archive in a multi-index search. Index patterns and aliases are allowed.
+func IndicesBoost(boosts map[string]float64) SearchOption {
+	return func(req *searchRequest) error {
+		indices := make([]string, 0, len(boosts))
+		for index := range boosts {
+			indices = append(indices, index)
+		}
+		sort.Strings(indices)
+
+		list := make([]map[string]float64, 0, len(indices))
+		for _, index := range indices {
+			list = append(list, map[string]float64{index: boosts[index]})
+		}
+		req.body["indices_boost"] = list
+		return nil
+	}
+}
+
+// SourceFilter restricts the returned _source to the fields matching includes and not matching
+// excludes. Both accept wildcards, e.g. "metadata.*".
+func SourceFilter(includes, excludes []string) SearchOption {
+	return func(req *searchRequest) error {
+		fsc := fetchSource(includes, excludes)
+		if fsc == nil {
+			return nil
+		}
+
+		src, err := fsc.Source()
+		if err != nil {
+			return err
+		}
+		req.body["_source"] = src
+		return nil
+	}
+}
+
+// RuntimeField defines a field computed by a painless script at search time (elasticsearch 7.11+),
+// e.g. to sort, aggregate or collapse on a derived value. typ is a field type like keyword, long or date;
+// the script passes values with emit(...). Options can add several runtime fields.
+func RuntimeField(name, typ, script string) SearchOption {
+	return func(req *searchRequest) error {
+		fields, _ := req.body["runtime_mappings"].(map[string]interface{})
+		if fields == nil {
+			fields = map[string]interface{}{}
+			req.body["runtime_mappings"] = fields
+		}
+		fields[name] = map[string]interface{}{"type": typ, "script": map[string]interface{}{"source": script}}
+		return nil
+	}
+}
+
+// SortBy adds sorters to the search. They are applied after any sort given in the query body.
+func SortBy(sorters ...elastic.Sorter) SearchOption {
+	return func(req *searchRequest) error {
+		var sort []interface{}
+		switch v := req.body["sort"].(type) {
+		case nil:
+		case []interface{}:
+			sort = v
+		default:
+			sort = []interface{}{v}
+		}
+
+		for _, sorter := range sorters {
+			src, err := sorter.Source()
+			if err != nil {
+				return err
+			}
+			sort = append(sort, src)
+		}
+		if len(sort) != 0 {
+			req.body["sort"] = sort
+		}
+		return nil
+	}
+}
+
+// SearchRaw sends body as the complete search request (query, size, aggs, sort, highlight, ...).
+func (s *DocType) SearchRaw(ctx context.Context, body string) (*elastic.SearchResult, error) {
+	if !json.Valid([]byte(body)) {
+		return nil, errors.New("search body is not valid json")
+	}
+	res, err := s.cl.conn.Search(s.readIndex()).Source(body).Do(ctx)
+	return res, rateLimited(err)
+}
+
+// SearchFields searches without _source and returns the given fields (wildcards allowed) in the
+// Fields of the hits. Unlike docvalue_fields or source filtering, the fields parameter
+// (elasticsearch 7.10+) also returns runtime fields and formats values according to the mapping.
+func (s *DocType) SearchFields(ctx context.Context, query interface{}, fields ...string) (*elastic.SearchResult, error) {
+	return s.search(ctx, []string{s.readIndex()}, query, []SearchOption{func(req *searchRequest) error {
+		req.body["fields"] = fields
+		req.body["_source"] = false
+		return nil
+	}})
+}
+
+func (s *DocType) search(ctx context.Context, indices []string, query interface{}, opts []SearchOption) (*elastic.SearchResult, error) {
+	body, err := searchBody(query)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &searchRequest{body: body, params: url.Values{}}
+	for _, opt := range opts {
+		if err := opt(req); err != nil {
+			return nil, err
+		}
+	}
+
+	path := "/" + strings.Join(indices, ",") + "/_search"
+	res, err := s.cl.perform(ctx, "POST", path, req.params, req.body)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := new(elastic.SearchResult)
+	if err := json.Unmarshal(res.Body, ret); err != nil {
+		return nil, err
+	}
+	for _, decode := range req.decoders {
+		if err := decode(res.Body); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// Count counts the documents matching query. A terminateAfter > 0 stops counting after that
+// many documents per shard; terminatedEarly then tells if the count is a lower bound.
+func (s *DocType) Count(ctx context.Context, query interface{}, terminateAfter int) (count int64, terminatedEarly bool, err error) {
+	body, err := searchBody(query)
+	if err != nil {
+		return 0, false, err
+	}
+	// the count api only accepts the query part of a search body
+	countBody := map[string]interface{}{}
+	if q, ok := body["query"]; ok {
+		countBody["query"] = q
+	}
+
+	params := url.Values{}
+	if terminateAfter > 0 {
+		params.Set("terminate_after", strconv.Itoa(terminateAfter))
+	}
+
+	res, err := s.cl.perform(ctx, "POST", "/"+s.readIndex()+"/_count", params, countBody)
+	if err != nil {
+		return 0, false, err
+	}
+
+	var r struct {
+		Count           int64 `json:"count"`
+		TerminatedEarly bool  `json:"terminated_early"`
+	}
+	if err := json.Unmarshal(res.Body, &r); err != nil {
+		return 0, false, err
+	}
+	return r.Count, r.TerminatedEarly, nil
+}
+
+// CountCapped counts the documents matching query up to limit. capped is true if more documents
+// match, in which case count is limit. Cheaper than a full count on large indices (e.g. "999+").
+func (s *DocType) CountCapped(ctx context.Context, query interface{}, limit int) (count int64, capped bool, err error) {
+	// one more than the limit to tell "exactly limit" from "more than limit"
+	count, early, err := s.Count(ctx, query, limit+1)
+	if err != nil {
+		return 0, false, err
+	}
+	if early || count > int64(limit) {
+		return int64(limit), true, nil
+	}
+	return count, false, nil
+}
+
+// querySource returns the json representation of a query given as elastic.Query or raw json.
+func querySource(query interface{}) (interface{}, error) {
+	switch q := query.(type) {
+	case elastic.Query:
+		return q.Source()
+	case string:
+		return json.RawMessage(q), nil
+	case []byte:
+		return json.RawMessage(q), nil
+	}
+	return query, nil
+}
+
+// searchBody turns a query into a search request body. Strings, byte slices and other json
+// values are taken as the full body, an elastic.Query becomes the query part of the body.
+func searchBody(query interface{}) (map[string]interface{}, error) {
+	body := map[string]interface{}{}
+
+	var d []byte
+	switch q := query.(type) {
+	case nil:
+		return body, nil
+	case elastic.Query:
+		src, err := q.Source()
+		if err != nil {
+			return nil, err
+		}
+		body["query"] = src
+		return body, nil
+	case string:
+		d = []byte(q)
+	case []byte:
+		d = q
+	case json.RawMessage:
+		d = q
+	default:
+		var err error
+		if d, err = json.Marshal(q); err != nil {
+			return nil, err
+		}
+	}
+
+	// UseNumber keeps large integers (ids, timestamps) intact when the body is sent again
+	dec := json.NewDecoder(bytes.NewReader(d))
+	dec.UseNumber()
+	if err := dec.Decode(&body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// TimeIndexLayout is the date layout appended to the base name of time based indices (base-2006.01.02).
+var TimeIndexLayout = "2006.01.02"
+
+// TimeRangeIndices returns the names of the daily indices covering start to end (both inclusive, UTC).
+func TimeRangeIndices(base string, start, end time.Time) []string {
+	var (
+		indices []string
+		day     = start.UTC().Truncate(24 * time.Hour)
+	)
+	for !day.After(end.UTC()) {
+		indices = append(indices, base+"-"+day.Format(TimeIndexLayout))
+		day = day.Add(24 * time.Hour)
+	}
+	return indices
+}
+
+// TimeRangePatterns returns the names of the daily indices covering start to end like TimeRangeIndices,
+// but with whole months and years collapsed into wildcard patterns (base-2017.01.*, base-2017.*.*)
+// so long ranges still fit into the request line. Days are only collapsed if TimeIndexLayout
+// contains the month (01) and day (02).
+func TimeRangePatterns(base string, start, end time.Time) []string {
+	var (
+		patterns    []string
+		monthLayout = strings.Replace(TimeIndexLayout, "02", "*", 1)
+		yearLayout  = strings.Replace(monthLayout, "01", "*", 1)
+		collapse    = monthLayout != TimeIndexLayout && yearLayout != monthLayout
+		last        = end.UTC().Truncate(24 * time.Hour)
+		day         = start.UTC().Truncate(24 * time.Hour)
+	)
+	for !day.After(last) {
+		nextYear := day.AddDate(1, 0, 0)
+		nextMonth := day.AddDate(0, 1, 0)
+		switch {
+		case collapse && day.YearDay() == 1 && !nextYear.After(last.AddDate(0, 0, 1)):
+			patterns = append(patterns, base+"-"+day.Format(yearLayout))
+			day = nextYear
+		case collapse && day.Day() == 1 && !nextMonth.After(last.AddDate(0, 0, 1)):
+			patterns = append(patterns, base+"-"+day.Format(monthLayout))
+			day = nextMonth
+		default:
+			patterns = append(patterns, base+"-"+day.Format(TimeIndexLayout))
+			day = day.AddDate(0, 0, 1)
+		}
+	}
+	return patterns
+}
+
+// SearchTimeRange searches only the daily indices of base covering start to end.
+// Days without an index are skipped. Whole months and years are searched by
+// wildcard patterns (see TimeRangePatterns).
+func (s *DocType) SearchTimeRange(ctx context.Context, base string, start, end time.Time, query interface{}, opts ...SearchOption) (*elastic.SearchResult, error) {
+	indices := TimeRangePatterns(base, start, end)
+	if len(indices) == 0 {
+		return nil, errors.New("end of time range is before its start")
+	}
+
+	opts = append(opts, func(req *searchRequest) error {
+		req.params.Set("ignore_unavailable", "true")
+		return nil
+	})
+	return s.search(ctx, indices, query, opts)
+}
+
+// SearchIn searches the given indices, aliases or wildcard patterns instead of the index of the doc type,
+// e.g. a single daily index, without creating a doc type for them.
+func (s *DocType) SearchIn(ctx context.Context, indices []string, query interface{}, opts ...SearchOption) (*elastic.SearchResult, error) {
+	if len(indices) == 0 {
+		return nil, errors.New("no indices to search")
+	}
+	return s.search(ctx, indices, query, opts)
+}
+
+// ChangedSince returns up to size documents whose timestamp field is at or after since, oldest first.
+// Use the field value of the last hit as since of the next call to pull changes incrementally;
+// documents sharing that timestamp are returned again.
+func (s *DocType) ChangedSince(ctx context.Context, field string, since time.Time, size int) ([]*elastic.SearchHit, error) {
+	query := elastic.NewRangeQuery(field).Gte(since.UTC().Format(time.RFC3339Nano))
+	res, err := s.search(ctx, []string{s.readIndex()}, query, []SearchOption{
+		SortBy(elastic.NewFieldSort(field).Asc()),
+		func(req *searchRequest) error {
+			req.body["size"] = size
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.Hits == nil {
+		return nil, nil
+	}
+	return res.Hits.Hits, nil
+}
+
+// SearchShardsResponse lists the shard copies a search would be executed on and their nodes.
+type SearchShardsResponse struct {
+	Nodes map[string]struct {
+		Name             string `json:"name"`
+		TransportAddress string `json:"transport_address"`
+	} `json:"nodes"`
+	// Shards holds one entry per shard with all copies of the shard.
+	Shards [][]struct {
+		Index          string `json:"index"`
+		Shard          int    `json:"shard"`
+		Node           string `json:"node"`
+		Primary        bool   `json:"primary"`
+		State          string `json:"state"`
+		RelocatingNode string `json:"relocating_node"`
+	} `json:"shards"`
+}
+
+// SearchShards returns the shards and nodes a search with the given routing (may be empty) would hit.
+func (s *DocType) SearchShards(ctx context.Context, routing string) (*SearchShardsResponse, error) {
+	params := url.Values{}
+	if routing != "" {
+		params.Set("routing", routing)
+	}
+
+	res, err := s.cl.perform(ctx, "GET", "/"+s.readIndex()+"/_search_shards", params, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := new(SearchShardsResponse)
+	if err := json.Unmarshal(res.Body, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
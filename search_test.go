@@ -0,0 +1,68 @@
+package eso
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var timeRangeIndicesTests = []struct {
+	start    time.Time
+	end      time.Time
+	expected []string
+}{
+	{time.Date(2017, 1, 30, 15, 0, 0, 0, time.UTC), time.Date(2017, 2, 1, 1, 0, 0, 0, time.UTC),
+		[]string{"logs-2017.01.30", "logs-2017.01.31", "logs-2017.02.01"}},
+	{time.Date(2017, 1, 30, 15, 0, 0, 0, time.UTC), time.Date(2017, 1, 30, 16, 0, 0, 0, time.UTC),
+		[]string{"logs-2017.01.30"}},
+	{time.Date(2017, 1, 30, 15, 0, 0, 0, time.UTC), time.Date(2017, 1, 29, 16, 0, 0, 0, time.UTC),
+		nil},
+}
+
+func TestTimeRangeIndices(t *testing.T) {
+	for _, tt := range timeRangeIndicesTests {
+		if actual := TimeRangeIndices("logs", tt.start, tt.end); !reflect.DeepEqual(actual, tt.expected) {
+			t.Error(actual, tt.expected)
+		}
+	}
+}
+
+var timeRangePatternsTests = []struct {
+	start    time.Time
+	end      time.Time
+	expected []string
+}{
+	{time.Date(2017, 1, 30, 15, 0, 0, 0, time.UTC), time.Date(2017, 2, 1, 1, 0, 0, 0, time.UTC),
+		[]string{"logs-2017.01.30", "logs-2017.01.31", "logs-2017.02.01"}},
+	{time.Date(2017, 1, 30, 0, 0, 0, 0, time.UTC), time.Date(2017, 3, 2, 0, 0, 0, 0, time.UTC),
+		[]string{"logs-2017.01.30", "logs-2017.01.31", "logs-2017.02.*", "logs-2017.03.01", "logs-2017.03.02"}},
+	{time.Date(2017, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2017, 2, 28, 23, 0, 0, 0, time.UTC),
+		[]string{"logs-2017.02.*"}},
+	{time.Date(2016, 12, 31, 0, 0, 0, 0, time.UTC), time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC),
+		[]string{"logs-2016.12.31", "logs-2017.*.*", "logs-2018.01.*", "logs-2018.02.01"}},
+	{time.Date(2017, 1, 30, 15, 0, 0, 0, time.UTC), time.Date(2017, 1, 29, 16, 0, 0, 0, time.UTC),
+		nil},
+}
+
+func TestTimeRangePatterns(t *testing.T) {
+	for _, tt := range timeRangePatternsTests {
+		if actual := TimeRangePatterns("logs", tt.start, tt.end); !reflect.DeepEqual(actual, tt.expected) {
+			t.Error(actual, tt.expected)
+		}
+	}
+
+	start, end := time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2019, 12, 31, 0, 0, 0, 0, time.UTC)
+	if patterns := TimeRangePatterns("logs", start, end); len(patterns) != 10 {
+		t.Error("expected one pattern per year", patterns)
+	}
+}
+
+func TestSortByEmpty(t *testing.T) {
+	req := &searchRequest{body: map[string]interface{}{}}
+	if err := SortBy()(req); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := req.body["sort"]; ok {
+		t.Error("expected no sort without sorters", req.body)
+	}
+}
@@ -0,0 +1,180 @@
+package eso
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// GetSettings returns the settings of the index with flat keys (e.g. "index.refresh_interval").
+func (s *Index) GetSettings(ctx context.Context) (map[string]interface{}, error) {
+	res, err := s.cl.conn.IndexGetSettings(s.name).FlatSettings(true).Do(ctx)
+	err = rateLimited(err)
+	if err != nil {
+		return nil, err
+	}
+
+	idx, ok := res[s.name]
+	if !ok {
+		// s.name is an alias: take the first index behind it
+		for _, v := range res {
+			idx = v
+			break
+		}
+	}
+	if idx == nil {
+		return map[string]interface{}{}, nil
+	}
+	return idx.Settings, nil
+}
+
+// putSettings updates dynamic settings of the index. A nil value resets a setting to its default.
+func (s *Index) putSettings(ctx context.Context, settings map[string]interface{}) error {
+	res, err := s.cl.conn.IndexPutSettings(s.name).BodyJson(settings).Do(ctx)
+	err = rateLimited(err)
+	if err == nil && !res.Acknowledged {
+		err = errors.New("elasticsearch did not acklowledge settings update")
+	}
+	return err
+}
+
+// BulkLoadMode disables replicas and refreshes on the index to speed up bulk loads.
+// The returned restore function puts back the values the index had before.
+func (s *Index) BulkLoadMode(ctx context.Context) (restore func(context.Context) error, err error) {
+	settings, err := s.GetSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// values not set on the index are nil and reset to the default on restore
+	orig := map[string]interface{}{
+		"index.number_of_replicas": settings["index.number_of_replicas"],
+		"index.refresh_interval":   settings["index.refresh_interval"],
+	}
+
+	err = s.putSettings(ctx, map[string]interface{}{
+		"index.number_of_replicas": 0,
+		"index.refresh_interval":   "-1",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context) error {
+		return s.putSettings(ctx, orig)
+	}, nil
+}
+
+// SetReadOnly blocks (or allows) writes to the index. Allowing writes also clears the
+// read_only_allow_delete block elasticsearch sets when the disk flood stage is reached.
+func (s *Index) SetReadOnly(ctx context.Context, readOnly bool) error {
+	if readOnly {
+		return s.putSettings(ctx, map[string]interface{}{"index.blocks.write": true})
+	}
+	return s.putSettings(ctx, map[string]interface{}{
+		"index.blocks.write":                  nil,
+		"index.blocks.read_only_allow_delete": nil,
+	})
+}
+
+// ClearReadOnly removes all write blocks (write, read_only, read_only_allow_delete) from the index.
+func (s *Index) ClearReadOnly(ctx context.Context) error {
+	return s.putSettings(ctx, map[string]interface{}{
+		"index.blocks.write":                  nil,
+		"index.blocks.read_only":              nil,
+		"index.blocks.read_only_allow_delete": nil,
+	})
+}
+
+// writeBlocks are the index blocks which reject writes.
+var writeBlocks = []string{
+	"index.blocks.read_only_allow_delete",
+	"index.blocks.read_only",
+	"index.blocks.write",
+}
+
+// IsWriteBlocked reports whether writes to the index are blocked. reason names the blocks
+// which are set, e.g. "index.blocks.read_only_allow_delete" after the disk flood stage
+// was reached. Use ClearReadOnly to remove them.
+func (s *Index) IsWriteBlocked(ctx context.Context) (blocked bool, reason string, err error) {
+	settings, err := s.GetSettings(ctx)
+	if err != nil {
+		return false, "", err
+	}
+
+	var set []string
+	for _, block := range writeBlocks {
+		if fmt.Sprint(settings[block]) == "true" {
+			set = append(set, block)
+		}
+	}
+	return len(set) != 0, strings.Join(set, ", "), nil
+}
+
+// dynamicSettingPrefixes are the settings (or groups of settings ending in ".") which can be
+// changed on an open index.
+var dynamicSettingPrefixes = []string{
+	"index.number_of_replicas",
+	"index.auto_expand_replicas",
+	"index.refresh_interval",
+	"index.max_result_window",
+	"index.max_inner_result_window",
+	"index.max_rescore_window",
+	"index.max_refresh_listeners",
+	"index.blocks.",
+	"index.routing.allocation.",
+	"index.routing.rebalance.",
+	"index.translog.",
+	"index.search.slowlog.",
+	"index.indexing.slowlog.",
+	"index.unassigned.node_left.delayed_timeout",
+}
+
+// GetDynamicSettings returns the settings of the index which can be changed at runtime (refresh_interval,
+// number_of_replicas, blocks.*, ...) with flat keys. Settings not set on the index are included with their
+// default value, so putting the result back restores the index completely.
+func (s *Index) GetDynamicSettings(ctx context.Context) (map[string]string, error) {
+	params := url.Values{"include_defaults": {"true"}, "flat_settings": {"true"}}
+	res, err := s.cl.perform(ctx, "GET", "/"+s.name+"/_settings", params, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var indices map[string]struct {
+		Settings map[string]interface{} `json:"settings"`
+		Defaults map[string]interface{} `json:"defaults"`
+	}
+	if err := json.Unmarshal(res.Body, &indices); err != nil {
+		return nil, err
+	}
+	idx, ok := indices[s.name]
+	if !ok {
+		// s.name is an alias: take the first index behind it
+		for _, v := range indices {
+			idx = v
+			break
+		}
+	}
+
+	ret := dynamicSettings(idx.Defaults)
+	for key, value := range dynamicSettings(idx.Settings) {
+		ret[key] = value
+	}
+	return ret, nil
+}
+
+func dynamicSettings(settings map[string]interface{}) map[string]string {
+	ret := map[string]string{}
+	for key, value := range settings {
+		for _, prefix := range dynamicSettingPrefixes {
+			if key == prefix || (strings.HasSuffix(prefix, ".") && strings.HasPrefix(key, prefix)) {
+				ret[key] = fmt.Sprint(value)
+				break
+			}
+		}
+	}
+	return ret
+}
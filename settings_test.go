@@ -0,0 +1,63 @@
+package eso
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestDynamicSettings(t *testing.T) {
+	settings := map[string]interface{}{
+		"index.number_of_shards":                    "5",
+		"index.number_of_replicas":                  "1",
+		"index.refresh_interval":                    "30s",
+		"index.refresh_interval_extra":              "x",
+		"index.blocks.write":                        "true",
+		"index.routing.allocation.require._name":    "node-1",
+		"index.analysis.analyzer.default.tokenizer": "standard",
+		"index.uuid":                                "abc",
+	}
+	expected := map[string]string{
+		"index.number_of_replicas":               "1",
+		"index.refresh_interval":                 "30s",
+		"index.blocks.write":                     "true",
+		"index.routing.allocation.require._name": "node-1",
+	}
+
+	if actual := dynamicSettings(settings); !reflect.DeepEqual(actual, expected) {
+		t.Error(actual, expected)
+	}
+}
+
+func TestGetDynamicSettings(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/unit_settings/_settings" {
+			w.Write([]byte(`{}`))
+			return
+		}
+		if r.URL.Query().Get("include_defaults") != "true" {
+			t.Error("expected the defaults to be requested", r.URL.RawQuery)
+		}
+		w.Write([]byte(`{"unit_settings": {
+			"settings": {"index.number_of_shards": "5", "index.refresh_interval": "30s"},
+			"defaults": {"index.refresh_interval": "1s", "index.number_of_replicas": "1", "index.codec": "default"}
+		}}`))
+	}))
+	defer srv.Close()
+
+	RegisterClient("settings_test", srv.URL)
+	expected := map[string]string{
+		"index.refresh_interval":   "30s",
+		"index.number_of_replicas": "1",
+	}
+
+	actual, err := NewIndex("unit_settings", "settings_test").GetDynamicSettings(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Error(actual, expected)
+	}
+}
@@ -0,0 +1,41 @@
+package eso
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// Snapshot snapshots only this index into repo and waits for the snapshot to complete.
+// An error is returned unless the snapshot state is SUCCESS.
+func (s *Index) Snapshot(ctx context.Context, repo, snapshotName string) error {
+	body := map[string]interface{}{
+		"indices":              s.name,
+		"include_global_state": false,
+	}
+	params := url.Values{"wait_for_completion": []string{"true"}}
+	path := "/_snapshot/" + url.PathEscape(repo) + "/" + url.PathEscape(snapshotName)
+
+	res, err := s.cl.perform(ctx, "PUT", path, params, body)
+	if err != nil {
+		return err
+	}
+
+	var ret struct {
+		Snapshot struct {
+			State    string `json:"state"`
+			Failures []struct {
+				Index  string `json:"index"`
+				Reason string `json:"reason"`
+			} `json:"failures"`
+		} `json:"snapshot"`
+	}
+	if err := json.Unmarshal(res.Body, &ret); err != nil {
+		return err
+	}
+	if ret.Snapshot.State != "SUCCESS" {
+		return fmt.Errorf("snapshot %s of index %s finished with state %s: %v", snapshotName, s.name, ret.Snapshot.State, ret.Snapshot.Failures)
+	}
+	return nil
+}
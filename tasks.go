@@ -0,0 +1,69 @@
+package eso
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// TaskIDs returns the ids ("node:number") of the running search tasks sent with a context
+// from WithOpaqueID(ctx, opaqueID). Pass them to CancelTask to abort a runaway search.
+func TaskIDs(ctx context.Context, clientName, opaqueID string) ([]string, error) {
+	cl, err := getClient(clientName)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{"detailed": []string{"true"}, "actions": []string{"*search*"}}
+	res, err := cl.perform(ctx, "GET", "/_tasks", params, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks struct {
+		Nodes map[string]struct {
+			Tasks map[string]struct {
+				Headers map[string]string `json:"headers"`
+			} `json:"tasks"`
+		} `json:"nodes"`
+	}
+	if err := json.Unmarshal(res.Body, &tasks); err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, node := range tasks.Nodes {
+		for id, task := range node.Tasks {
+			if task.Headers["X-Opaque-Id"] == opaqueID {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// CancelTask cancels the running task with the given id ("node:number").
+func CancelTask(ctx context.Context, clientName, taskID string) error {
+	cl, err := getClient(clientName)
+	if err != nil {
+		return err
+	}
+
+	res, err := cl.perform(ctx, "POST", "/_tasks/"+url.PathEscape(taskID)+"/_cancel", nil, nil)
+	if err != nil {
+		return err
+	}
+
+	var ret struct {
+		NodeFailures []json.RawMessage `json:"node_failures"`
+		TaskFailures []json.RawMessage `json:"task_failures"`
+	}
+	if err := json.Unmarshal(res.Body, &ret); err != nil {
+		return err
+	}
+	if len(ret.NodeFailures) != 0 || len(ret.TaskFailures) != 0 {
+		return fmt.Errorf("elasticsearch failed to cancel task %s: %s", taskID, res.Body)
+	}
+	return nil
+}
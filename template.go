@@ -0,0 +1,127 @@
+package eso
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// hasMatchingTemplate checks if an index template applies to the index name.
+func (s *Index) hasMatchingTemplate(ctx context.Context) (bool, error) {
+	res, err := s.cl.perform(ctx, "GET", "/_template", nil, nil)
+	if err != nil {
+		return false, err
+	}
+
+	// elasticsearch 5 uses "template", 6+ "index_patterns"
+	var templates map[string]struct {
+		Template      string   `json:"template"`
+		IndexPatterns []string `json:"index_patterns"`
+	}
+	if err := json.Unmarshal(res.Body, &templates); err != nil {
+		return false, err
+	}
+
+	for _, t := range templates {
+		patterns := t.IndexPatterns
+		if t.Template != "" {
+			patterns = append(patterns, t.Template)
+		}
+		for _, p := range patterns {
+			if wildcardMatch(p, s.name) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// createEmptyIndex creates an index without settings and mappings.
+func (s *Index) createEmptyIndex(index string) error {
+	createIndex, err := s.cl.conn.CreateIndex(index).Do(context.TODO())
+	if alreadyExists(err) {
+		return nil
+	}
+	err = rateLimited(err)
+	if err == nil && !createIndex.Acknowledged {
+		err = errors.New("elasticsearch did not acklowledge new index")
+	}
+	return err
+}
+
+// wildcardMatch matches name against an index pattern where * matches any number of characters.
+func wildcardMatch(pattern, name string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == name
+	}
+
+	if !strings.HasPrefix(name, parts[0]) {
+		return false
+	}
+	name = name[len(parts[0]):]
+
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(name, part)
+		if i < 0 {
+			return false
+		}
+		name = name[i+len(part):]
+	}
+	return strings.HasSuffix(name, parts[len(parts)-1])
+}
+
+// nonReusableSettings are settings elasticsearch sets on index creation which must not be
+// part of a template.
+var nonReusableSettings = []string{
+	"index.uuid",
+	"index.creation_date",
+	"index.provided_name",
+	"index.version.",
+	"index.resize.",
+	"index.routing.allocation.initial_recovery.",
+	"index.blocks.",
+}
+
+// reusableSettings returns the flat settings without the ones bound to the existing index.
+func reusableSettings(settings map[string]interface{}) map[string]interface{} {
+	ret := make(map[string]interface{}, len(settings))
+	for key, value := range settings {
+		reusable := true
+		for _, name := range nonReusableSettings {
+			if key == name || (strings.HasSuffix(name, ".") && strings.HasPrefix(key, name)) {
+				reusable = false
+				break
+			}
+		}
+		if reusable {
+			ret[key] = value
+		}
+	}
+	return ret
+}
+
+// ExportTemplateBody returns the current settings and mappings of the index as an index template
+// body for PutIndexTemplate, matching the index name. Settings bound to the existing index
+// (uuid, creation date, version, blocks, ...) are left out.
+func (s *Index) ExportTemplateBody(ctx context.Context) (string, error) {
+	settings, err := s.GetSettings(ctx)
+	if err != nil {
+		return "", err
+	}
+	mappings, err := s.GetMapping(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	body, err := json.MarshalIndent(map[string]interface{}{
+		"index_patterns": []string{s.name},
+		"settings":       reusableSettings(settings),
+		"mappings":       mappings,
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
@@ -0,0 +1,50 @@
+package eso
+
+import "testing"
+
+var wildcardMatchTests = []struct {
+	pattern  string
+	name     string
+	expected bool
+}{
+	{"te*", "test", true},
+	{"te*", "unit_test", false},
+	{"*", "anything", true},
+	{"logs-*-2017.*", "logs-app-2017.01.02", true},
+	{"logs-*-2017.*", "logs-app-2018.01.02", false},
+	{"*-archive", "mails-archive", true},
+	{"a*a", "a", false},
+	{"index1", "index1", true},
+}
+
+func TestWildcardMatch(t *testing.T) {
+	for _, tt := range wildcardMatchTests {
+		if actual := wildcardMatch(tt.pattern, tt.name); actual != tt.expected {
+			t.Errorf("wildcardMatch(%s, %s): expected %v, actual %v", tt.pattern, tt.name, tt.expected, actual)
+		}
+	}
+}
+
+func TestReusableSettings(t *testing.T) {
+	settings := reusableSettings(map[string]interface{}{
+		"index.number_of_shards":                "3",
+		"index.refresh_interval":                "5s",
+		"index.uuid":                            "abc",
+		"index.creation_date":                   "1500000000000",
+		"index.provided_name":                   "unit_test",
+		"index.version.created":                 "5060399",
+		"index.blocks.read_only_allow_delete":   "true",
+		"index.analysis.analyzer.html.type":     "custom",
+		"index.routing.allocation.include.zone": "a",
+	})
+
+	expected := []string{"index.analysis.analyzer.html.type", "index.number_of_shards", "index.refresh_interval", "index.routing.allocation.include.zone"}
+	if len(settings) != len(expected) {
+		t.Error(settings)
+	}
+	for _, key := range expected {
+		if _, ok := settings[key]; !ok {
+			t.Error("missing", key)
+		}
+	}
+}
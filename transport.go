@@ -0,0 +1,156 @@
+package eso
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CaptureFunc receives the method, url and body of an outgoing request.
+type CaptureFunc func(method, url string, body []byte)
+
+// InspectFunc receives the status code and headers of a response along with the
+// method and url of its request.
+type InspectFunc func(method, url string, status int, header http.Header)
+
+type captureKey struct{}
+
+// WithCapture returns a context which makes all requests sent with it call fn with
+// the request body. Use it to inspect the exact json of a single call.
+func WithCapture(ctx context.Context, fn CaptureFunc) context.Context {
+	return context.WithValue(ctx, captureKey{}, fn)
+}
+
+type opaqueIDKey struct{}
+
+// WithOpaqueID returns a context which tags all requests sent with it with id (X-Opaque-Id header).
+// Running tasks of tagged requests can be found with TaskIDs, e.g. to cancel a search.
+func WithOpaqueID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, opaqueIDKey{}, id)
+}
+
+// transport wraps the http transport of a client to implement the options
+// working on raw requests and responses.
+type transport struct {
+	cfg  *clientConfig
+	next http.RoundTripper
+}
+
+func (s *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	capture := s.cfg.capture
+	if fn, ok := req.Context().Value(captureKey{}).(CaptureFunc); ok {
+		capture = fn
+	}
+
+	// the body is kept to pass it to capture and to resend it on retries
+	var body []byte
+	if req.Body != nil && (capture != nil || s.cfg.retries > 0) {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+
+		req = req.Clone(req.Context())
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	if capture != nil {
+		capture(req.Method, req.URL.String(), body)
+	}
+
+	if id, ok := req.Context().Value(opaqueIDKey{}).(string); ok {
+		req = req.Clone(req.Context())
+		req.Header.Set("X-Opaque-Id", id)
+	}
+	if s.cfg.compatibleWith != 0 {
+		req = req.Clone(req.Context())
+		setCompatibilityHeaders(req.Header, s.cfg.compatibleWith)
+	}
+
+	// with the default classification connection errors are only retried for idempotent requests
+	isRetryable, retryConnErr := s.cfg.isRetryable, true
+	if isRetryable == nil {
+		isRetryable, retryConnErr = DefaultIsRetryable, idempotent(req)
+	}
+
+	backoff := 100 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && body != nil {
+			req = req.Clone(req.Context())
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		res, err := s.next.RoundTrip(req)
+		status := 0
+		if err == nil {
+			status = res.StatusCode
+			if s.cfg.inspect != nil {
+				s.cfg.inspect(req.Method, req.URL.String(), status, inspectHeader(res.Header, s.cfg.inspectHeaders))
+			}
+		}
+		if attempt >= s.cfg.retries || req.Context().Err() != nil || !isRetryable(status, err) || (err != nil && !retryConnErr) {
+			return res, err
+		}
+
+		if res != nil {
+			io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// DefaultIsRetryable classifies 429 too many requests responses and connection errors as retryable.
+// Used by RetryOn, connection errors are retried for idempotent requests only.
+func DefaultIsRetryable(statusCode int, err error) bool {
+	return err != nil || statusCode == http.StatusTooManyRequests
+}
+
+// idempotent reports if req can be resent after a connection error without risking a second write:
+// GET, HEAD and PUT requests and POST requests on a document id (/index/type/id, /index/type/id/_update).
+// A POST without id may have created a document before the connection broke.
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut:
+		return true
+	case http.MethodPost:
+		segs := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+		return len(segs) >= 3 && !strings.HasPrefix(segs[2], "_")
+	}
+	return false
+}
+
+// setCompatibilityHeaders sets the Accept and Content-Type headers requesting the rest api
+// compatibility of the given major version. Content-Type is set for requests with a body only.
+func setCompatibilityHeaders(header http.Header, major int) {
+	mediaType := "application/vnd.elasticsearch+json; compatible-with=" + strconv.Itoa(major)
+	header.Set("Accept", mediaType)
+	if header.Get("Content-Type") != "" {
+		header.Set("Content-Type", mediaType)
+	}
+}
+
+// inspectHeader returns a copy of the selected headers, or of all headers if none are selected.
+func inspectHeader(header http.Header, selected []string) http.Header {
+	if len(selected) == 0 {
+		return header.Clone()
+	}
+
+	ret := http.Header{}
+	for _, name := range selected {
+		if v := header.Values(name); len(v) != 0 {
+			ret[http.CanonicalHeaderKey(name)] = append([]string(nil), v...)
+		}
+	}
+	return ret
+}
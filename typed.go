@@ -0,0 +1,77 @@
+package eso
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+// SearchTyped searches query and decodes the source of every hit into T.
+// Struct fields tagged `es:"_score"` (float64), `es:"_id"` or `es:"_index"` (string) are set to
+// the score, id and index of the hit. Tag them `json:"-"` too if the source has fields of the same name.
+func SearchTyped[T any](ctx context.Context, dt *DocType, query interface{}, opts ...SearchOption) ([]T, error) {
+	res, err := dt.search(ctx, []string{dt.readIndex()}, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	if res.Hits == nil {
+		return nil, nil
+	}
+
+	docs := make([]T, 0, len(res.Hits.Hits))
+	for _, hit := range res.Hits.Hits {
+		var doc T
+		if err := decodeHit(hit, &doc); err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// decodeHit decodes the source of hit into target and sets the fields tagged with meta fields (see SearchTyped).
+func decodeHit(hit *elastic.SearchHit, target interface{}) error {
+	if hit.Source != nil {
+		if err := decodeSource(*hit.Source, target); err != nil {
+			return err
+		}
+	}
+	return setHitMeta(hit, reflect.ValueOf(target))
+}
+
+func setHitMeta(hit *elastic.SearchHit, v reflect.Value) error {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("es")
+		if tag == "" || !t.Field(i).IsExported() {
+			continue
+		}
+
+		f := v.Field(i)
+		switch {
+		case tag == "_score" && f.Kind() == reflect.Float64:
+			if hit.Score != nil {
+				f.SetFloat(*hit.Score)
+			}
+		case tag == "_id" && f.Kind() == reflect.String:
+			f.SetString(hit.Id)
+		case tag == "_index" && f.Kind() == reflect.String:
+			f.SetString(hit.Index)
+		default:
+			return fmt.Errorf("field %s: unsupported es tag %q for type %s", t.Field(i).Name, tag, f.Type())
+		}
+	}
+	return nil
+}
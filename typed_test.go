@@ -0,0 +1,44 @@
+package eso
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gopkg.in/olivere/elastic.v5"
+)
+
+type scoredDoc struct {
+	Title string  `json:"title"`
+	ID    string  `json:"-" es:"_id"`
+	Index string  `json:"-" es:"_index"`
+	Score float64 `json:"-" es:"_score"`
+}
+
+func TestDecodeHit(t *testing.T) {
+	score := 1.5
+	src := json.RawMessage(`{"title":"hello"}`)
+	hit := &elastic.SearchHit{Id: "1", Index: "docs", Score: &score, Source: &src}
+
+	var doc scoredDoc
+	if err := decodeHit(hit, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if expected := (scoredDoc{Title: "hello", ID: "1", Index: "docs", Score: 1.5}); doc != expected {
+		t.Error(doc, expected)
+	}
+
+	var ptr *scoredDoc
+	if err := decodeHit(hit, &ptr); err != nil {
+		t.Fatal(err)
+	}
+	if ptr == nil || ptr.ID != "1" {
+		t.Error(ptr)
+	}
+
+	var invalid struct {
+		Score int `es:"_score"`
+	}
+	if err := decodeHit(hit, &invalid); err == nil {
+		t.Error("expected error for int score field")
+	}
+}
@@ -0,0 +1,47 @@
+package eso
+
+import (
+	"net/url"
+	"strconv"
+)
+
+// WriteOption sets a parameter of a write request (IndexDoc, Update).
+type WriteOption func(params url.Values)
+
+// chunkSizeParam carries the ChunkSize option; it is never sent to elasticsearch.
+const chunkSizeParam = "chunk_size"
+
+// IfSeqNo only writes the document if it was not changed since it was read with the given
+// sequence number and primary term (see GetSeqNo). Elasticsearch answers with a conflict otherwise.
+// Bulk requests fail with this option.
+func IfSeqNo(seqNo, primaryTerm int64) WriteOption {
+	return func(params url.Values) {
+		params.Set("if_seq_no", strconv.FormatInt(seqNo, 10))
+		params.Set("if_primary_term", strconv.FormatInt(primaryTerm, 10))
+	}
+}
+
+// WaitForActiveShards waits until the given number of shard copies ("all" or a number)
+// are active before writing. Also applies to bulk requests.
+func WaitForActiveShards(shards string) WriteOption {
+	return func(params url.Values) {
+		params.Set("wait_for_active_shards", shards)
+	}
+}
+
+// CreateOnly only writes the document if no document with the same id exists.
+// Elasticsearch answers with a conflict otherwise.
+// In bulk requests it applies to every document.
+func CreateOnly() WriteOption {
+	return func(params url.Values) {
+		params.Set("op_type", "create")
+	}
+}
+
+// ChunkSize sets the number of documents IndexMany and IndexManyByField send per bulk request.
+// A size <= 0 uses DefaultBulkSize. Other requests ignore it.
+func ChunkSize(size int) WriteOption {
+	return func(params url.Values) {
+		params.Set(chunkSizeParam, strconv.Itoa(size))
+	}
+}